@@ -48,6 +48,19 @@ const (
 	DeviceIDStrategyIndex = "index"
 )
 
+// Constants to represent the various allocation policies
+const (
+	AllocationPolicyAligned     = "aligned"
+	AllocationPolicyDistributed = "distributed"
+	AllocationPolicyFirstFit    = "first-fit"
+)
+
+// Constants to represent the clock throttle reasons used in health checks
+const (
+	ThrottleReasonThermal = "thermal"
+	ThrottleReasonPower   = "power"
+)
+
 // Constants related to generating CDI specifications
 const (
 	DefaultCDIAnnotationPrefix = cdiapi.AnnotationPrefix
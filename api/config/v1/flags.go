@@ -38,6 +38,8 @@ func updateFromCLIFlag[T any](pflag **T, c *cli.Context, flagName string) {
 			*flag = ptr(c.StringSlice(flagName))
 		case **bool:
 			*flag = ptr(c.Bool(flagName))
+		case **float64:
+			*flag = ptr(c.Float64(flagName))
 		case **Duration:
 			if gf, ok := c.Generic(flagName).(*DurationValue); ok && gf.Value != nil {
 				*flag = gf.Value
@@ -79,6 +81,43 @@ type PluginCommandLineFlags struct {
 	CDIAnnotationPrefix *string                 `json:"cdiAnnotationPrefix" yaml:"cdiAnnotationPrefix"`
 	NvidiaCTKPath       *string                 `json:"nvidiaCTKPath"       yaml:"nvidiaCTKPath"`
 	ContainerDriverRoot *string                 `json:"containerDriverRoot" yaml:"containerDriverRoot"`
+	NCCLTopologyHints   *bool                   `json:"ncclTopologyHints"   yaml:"ncclTopologyHints"`
+	PreAllocateHook     *string                 `json:"preAllocateHook"     yaml:"preAllocateHook"`
+	PostAllocateHook    *string                 `json:"postAllocateHook"    yaml:"postAllocateHook"`
+	// PreserveEnv lists the envvars that the plugin does not set in allocate
+	// responses so that values set in the container spec take effect. An
+	// entry ending in '*' matches all envvars with the preceding prefix.
+	PreserveEnv *[]string `json:"preserveEnv" yaml:"preserveEnv"`
+	// AllocationPolicies is the ordered list of policies that are tried when
+	// determining a preferred allocation. A policy is skipped if it does not
+	// apply to the available devices or fails.
+	AllocationPolicies *[]string `json:"allocationPolicies" yaml:"allocationPolicies"`
+	// CanaryAllocationPolicy is a policy whose allocations are computed
+	// alongside the configured policies and compared against them. Its
+	// allocations are only logged and are never returned to the kubelet.
+	CanaryAllocationPolicy *string `json:"canaryAllocationPolicy" yaml:"canaryAllocationPolicy"`
+	// AllocateMemoryWait is how long an allocation of replicated devices waits
+	// for the memory of the requested replicas to be freed by containers that
+	// previously used them. A value of zero disables the wait.
+	AllocateMemoryWait *Duration `json:"allocateMemoryWait" yaml:"allocateMemoryWait"`
+	// FabricReadyTimeout is how long the GPU fabric may take to become ready
+	// on NVSwitch systems before this is reported as a failure. Devices on
+	// GPUs whose fabric is not ready are unhealthy until it is, regardless of
+	// this timeout.
+	FabricReadyTimeout *Duration `json:"fabricReadyTimeout" yaml:"fabricReadyTimeout"`
+	// ThrottleHealthChecks lists the clock throttle reasons that mark the
+	// devices on a GPU as unhealthy while the GPU is throttled for them.
+	ThrottleHealthChecks *[]string `json:"throttleHealthChecks" yaml:"throttleHealthChecks"`
+	// ThrottledCapacity is the fraction [0, 1) of the devices on a throttled
+	// GPU that remain healthy.
+	ThrottledCapacity *float64 `json:"throttledCapacity" yaml:"throttledCapacity"`
+	// MpsHealthCheckInterval is how often the MPS control daemon of each
+	// resource shared using MPS is probed.
+	MpsHealthCheckInterval *Duration `json:"mpsHealthCheckInterval" yaml:"mpsHealthCheckInterval"`
+	// MpsDegradedLatency is the probe latency above which an MPS control
+	// daemon is considered degraded and the devices shared using it are
+	// marked as unhealthy.
+	MpsDegradedLatency *Duration `json:"mpsDegradedLatency" yaml:"mpsDegradedLatency"`
 }
 
 // deviceListStrategyFlag is a custom type for parsing the deviceListStrategy flag.
@@ -157,6 +196,30 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.Plugin.NvidiaCTKPath, c, n)
 			case "container-driver-root":
 				updateFromCLIFlag(&f.Plugin.ContainerDriverRoot, c, n)
+			case "nccl-topology-hints":
+				updateFromCLIFlag(&f.Plugin.NCCLTopologyHints, c, n)
+			case "pre-allocate-hook":
+				updateFromCLIFlag(&f.Plugin.PreAllocateHook, c, n)
+			case "post-allocate-hook":
+				updateFromCLIFlag(&f.Plugin.PostAllocateHook, c, n)
+			case "preserve-env":
+				updateFromCLIFlag(&f.Plugin.PreserveEnv, c, n)
+			case "allocation-policy":
+				updateFromCLIFlag(&f.Plugin.AllocationPolicies, c, n)
+			case "canary-allocation-policy":
+				updateFromCLIFlag(&f.Plugin.CanaryAllocationPolicy, c, n)
+			case "allocate-memory-wait":
+				updateFromCLIFlag(&f.Plugin.AllocateMemoryWait, c, n)
+			case "fabric-ready-timeout":
+				updateFromCLIFlag(&f.Plugin.FabricReadyTimeout, c, n)
+			case "throttle-health-checks":
+				updateFromCLIFlag(&f.Plugin.ThrottleHealthChecks, c, n)
+			case "throttled-capacity":
+				updateFromCLIFlag(&f.Plugin.ThrottledCapacity, c, n)
+			case "mps-health-check-interval":
+				updateFromCLIFlag(&f.Plugin.MpsHealthCheckInterval, c, n)
+			case "mps-degraded-latency":
+				updateFromCLIFlag(&f.Plugin.MpsDegradedLatency, c, n)
 			}
 			// GFD specific flags
 			if f.GFD == nil {
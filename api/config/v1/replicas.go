@@ -70,7 +70,7 @@ func (rrs *ReplicatedResources) isReplicated() bool {
 		return false
 	}
 	for _, rr := range rrs.Resources {
-		if rr.Replicas > 1 {
+		if rr.Replicas > 1 || rr.ReplicasPerGiB > 0 {
 			return true
 		}
 	}
@@ -78,11 +78,35 @@ func (rrs *ReplicatedResources) isReplicated() bool {
 }
 
 // ReplicatedResource represents a resource to be replicated.
+// Exactly one of Replicas or ReplicasPerGiB is expected to be set. If
+// ReplicasPerGiB is set, the number of replicas for each device scales with
+// the total memory of that device.
 type ReplicatedResource struct {
-	Name     ResourceName      `json:"name"             yaml:"name"`
-	Rename   ResourceName      `json:"rename,omitempty" yaml:"rename,omitempty"`
-	Devices  ReplicatedDevices `json:"devices"          yaml:"devices,flow"`
-	Replicas int               `json:"replicas"         yaml:"replicas"`
+	Name           ResourceName      `json:"name"                     yaml:"name"`
+	Rename         ResourceName      `json:"rename,omitempty"         yaml:"rename,omitempty"`
+	Devices        ReplicatedDevices `json:"devices"                  yaml:"devices,flow"`
+	Replicas       int               `json:"replicas"                 yaml:"replicas"`
+	ReplicasPerGiB float64           `json:"replicasPerGiB,omitempty" yaml:"replicasPerGiB,omitempty"`
+	// PipeGroupID is only applicable to MPS. If set, the MPS pipe directory
+	// for the resource is owned by this group and is not accessible to other
+	// users. Containers consuming the resource must include the group in
+	// their supplemental groups.
+	PipeGroupID *uint32 `json:"pipeGroupID,omitempty" yaml:"pipeGroupID,omitempty"`
+}
+
+// ReplicasFor returns the number of replicas to create for a device with the
+// specified total memory (in bytes).
+// If ReplicasPerGiB is set, the replica count is rounded down but never
+// drops below the minimum of 2 replicas for a shared device.
+func (r *ReplicatedResource) ReplicasFor(totalMemory uint64) int {
+	if r.ReplicasPerGiB <= 0 {
+		return r.Replicas
+	}
+	replicas := int(float64(totalMemory) / (1 << 30) * r.ReplicasPerGiB)
+	if replicas < 2 {
+		return 2
+	}
+	return replicas
 }
 
 // ReplicatedDevices encapsulates the set of devices that should be replicated for a given resource.
@@ -241,18 +265,36 @@ func (s *ReplicatedResource) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	replicas, exists := rr["replicas"]
-	if !exists {
+	replicas, replicasExists := rr["replicas"]
+	replicasPerGiB, replicasPerGiBExists := rr["replicasPerGiB"]
+	switch {
+	case replicasExists && replicasPerGiBExists:
+		return fmt.Errorf("only one of replicas or replicasPerGiB can be specified")
+	case replicasExists:
+		err = json.Unmarshal(replicas, &s.Replicas)
+		if err != nil {
+			return err
+		}
+		if s.Replicas < 2 {
+			return fmt.Errorf("number of replicas must be >= 2")
+		}
+	case replicasPerGiBExists:
+		err = json.Unmarshal(replicasPerGiB, &s.ReplicasPerGiB)
+		if err != nil {
+			return err
+		}
+		if s.ReplicasPerGiB <= 0 {
+			return fmt.Errorf("number of replicas per GiB must be > 0")
+		}
+	default:
 		return fmt.Errorf("no replicas specified")
 	}
 
-	err = json.Unmarshal(replicas, &s.Replicas)
-	if err != nil {
-		return err
-	}
-
-	if s.Replicas < 2 {
-		return fmt.Errorf("number of replicas must be >= 2")
+	if pipeGroupID, exists := rr["pipeGroupID"]; exists {
+		err = json.Unmarshal(pipeGroupID, &s.PipeGroupID)
+		if err != nil {
+			return err
+		}
 	}
 
 	rename, exists := rr["rename"]
@@ -339,6 +339,53 @@ func TestUnmarshalReplicatedResource(t *testing.T) {
 				Rename:   NoErrorNewResourceName("valid-shared"),
 			},
 		},
+		{
+			input: `{
+				"name": "valid",
+				"replicasPerGiB": 0.5
+			}`,
+			output: ReplicatedResource{
+				Name:           NoErrorNewResourceName("valid"),
+				Devices:        ReplicatedDevices{All: true},
+				ReplicasPerGiB: 0.5,
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicasPerGiB": 0
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"pipeGroupID": 2000
+			}`,
+			output: ReplicatedResource{
+				Name:        NoErrorNewResourceName("valid"),
+				Devices:     ReplicatedDevices{All: true},
+				Replicas:    2,
+				PipeGroupID: ptr(uint32(2000)),
+			},
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"pipeGroupID": -1
+			}`,
+			err: true,
+		},
+		{
+			input: `{
+				"name": "valid",
+				"replicas": 2,
+				"replicasPerGiB": 0.5
+			}`,
+			err: true,
+		},
 		{
 			input: `{
 				"name": "$invalid$",
@@ -464,3 +511,43 @@ func TestUnmarshalReplicatedResources(t *testing.T) {
 		})
 	}
 }
+
+func TestReplicasFor(t *testing.T) {
+	testCases := []struct {
+		description string
+		resource    ReplicatedResource
+		totalMemory uint64
+		expected    int
+	}{
+		{
+			description: "fixed replicas ignore memory",
+			resource:    ReplicatedResource{Replicas: 4},
+			totalMemory: 80 << 30,
+			expected:    4,
+		},
+		{
+			description: "replicas scale with memory",
+			resource:    ReplicatedResource{ReplicasPerGiB: 0.25},
+			totalMemory: 80 << 30,
+			expected:    20,
+		},
+		{
+			description: "replicas are rounded down",
+			resource:    ReplicatedResource{ReplicasPerGiB: 0.25},
+			totalMemory: 23 << 30,
+			expected:    5,
+		},
+		{
+			description: "replicas have a minimum of 2",
+			resource:    ReplicatedResource{ReplicasPerGiB: 0.25},
+			totalMemory: 4 << 30,
+			expected:    2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.resource.ReplicasFor(tc.totalMemory))
+		})
+	}
+}
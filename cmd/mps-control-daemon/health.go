@@ -0,0 +1,140 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// healthProbeTimeout defines how long the MPS daemon for a single resource may
+// take to respond to a health probe. This is below the timeout of the
+// readiness probe so that unresponsive daemons are reported as unhealthy
+// instead of the probe timing out.
+const healthProbeTimeout = 20 * time.Second
+
+// daemonHealth is the health of the MPS daemon for a single resource.
+type daemonHealth struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// healthReport is the health of all MPS daemons keyed by resource name.
+type healthReport map[spec.ResourceName]daemonHealth
+
+// newHealthCommand constructs a command that checks the health of the MPS
+// daemons. This is intended to be used as an exec probe in the container
+// running the daemons.
+func newHealthCommand(cfg *Config) *cli.Command {
+	c := cli.Command{
+		Name:  "health",
+		Usage: "Check that the MPS daemons for all MPS-enabled resources are ready and responding",
+		Action: func(c *cli.Context) error {
+			return health(c, cfg)
+		},
+	}
+
+	return &c
+}
+
+// health prints the health of each MPS daemon as JSON and returns an error if
+// the daemons have not been started or any of them is unhealthy. The daemons
+// are determined from the files created when they are started so that NVML
+// need not be initialized for each probe.
+func health(c *cli.Context, cfg *Config) error {
+	if _, err := os.Stat(readyFilePath); err != nil {
+		return fmt.Errorf("MPS daemons are not ready: %w", err)
+	}
+
+	config, err := cfg.loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("unable to load config: %v", err)
+	}
+	driverRoot := driverroot.Root(*config.Flags.Plugin.ContainerDriverRoot)
+
+	resources, err := mps.ContainerRoot.StartedResources()
+	if err != nil {
+		return fmt.Errorf("failed to get started MPS daemons: %w", err)
+	}
+
+	probes := make(map[spec.ResourceName]func(context.Context) error)
+	for _, resource := range resources {
+		probes[resource] = func(ctx context.Context) error {
+			return mps.AssertHealthy(ctx, mps.ContainerRoot, driverRoot, resource)
+		}
+	}
+	report := checkHealth(c.Context, probes, healthProbeTimeout)
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal health report: %w", err)
+	}
+	fmt.Println(string(output))
+
+	return report.err()
+}
+
+// checkHealth runs the specified probes concurrently and returns the resulting
+// report. Each probe is cancelled if it does not complete within the timeout.
+func checkHealth(ctx context.Context, probes map[spec.ResourceName]func(context.Context) error, timeout time.Duration) healthReport {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	report := make(healthReport)
+	for resource, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			h := daemonHealth{Healthy: true}
+			if err := probe(ctx); err != nil {
+				h = daemonHealth{Healthy: false, Error: err.Error()}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report[resource] = h
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// err returns an error listing the unhealthy resources in the report.
+func (r healthReport) err() error {
+	var errs error
+	for resource, h := range r {
+		if !h.Healthy {
+			errs = errors.Join(errs, fmt.Errorf("MPS daemon for %v is unhealthy: %v", resource, h.Error))
+		}
+	}
+	return errs
+}
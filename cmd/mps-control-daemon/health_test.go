@@ -0,0 +1,97 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestCheckHealth(t *testing.T) {
+	healthy := func(context.Context) error { return nil }
+	unhealthy := func(context.Context) error { return errors.New("timed out") }
+	unresponsive := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	testCases := []struct {
+		description    string
+		probes         map[spec.ResourceName]func(context.Context) error
+		expectedReport healthReport
+		expectedError  bool
+	}{
+		{
+			description:    "no daemons",
+			probes:         map[spec.ResourceName]func(context.Context) error{},
+			expectedReport: healthReport{},
+		},
+		{
+			description: "all daemons healthy",
+			probes: map[spec.ResourceName]func(context.Context) error{
+				"nvidia.com/gpu":        healthy,
+				"nvidia.com/gpu.shared": healthy,
+			},
+			expectedReport: healthReport{
+				"nvidia.com/gpu":        {Healthy: true},
+				"nvidia.com/gpu.shared": {Healthy: true},
+			},
+		},
+		{
+			description: "one daemon unhealthy",
+			probes: map[spec.ResourceName]func(context.Context) error{
+				"nvidia.com/gpu":        healthy,
+				"nvidia.com/gpu.shared": unhealthy,
+			},
+			expectedReport: healthReport{
+				"nvidia.com/gpu":        {Healthy: true},
+				"nvidia.com/gpu.shared": {Healthy: false, Error: "timed out"},
+			},
+			expectedError: true,
+		},
+		{
+			description: "one daemon unresponsive",
+			probes: map[spec.ResourceName]func(context.Context) error{
+				"nvidia.com/gpu":        healthy,
+				"nvidia.com/gpu.shared": unresponsive,
+			},
+			expectedReport: healthReport{
+				"nvidia.com/gpu":        {Healthy: true},
+				"nvidia.com/gpu.shared": {Healthy: false, Error: context.DeadlineExceeded.Error()},
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			report := checkHealth(context.Background(), tc.probes, 10*time.Millisecond)
+			require.Equal(t, tc.expectedReport, report)
+			if tc.expectedError {
+				require.Error(t, report.err())
+				return
+			}
+			require.NoError(t, report.err())
+		})
+	}
+}
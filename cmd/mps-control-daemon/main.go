@@ -17,13 +17,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/urfave/cli/v2"
 	"k8s.io/klog/v2"
 
@@ -33,13 +36,24 @@ import (
 
 	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mount"
 	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/chaos"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 	"github.com/NVIDIA/k8s-device-plugin/internal/info"
+	"github.com/NVIDIA/k8s-device-plugin/internal/reaper"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 )
 
+// maxParallelDaemonStarts defines the maximum number of MPS daemons that are
+// started concurrently.
+const maxParallelDaemonStarts = 4
+
+// readyFilePath is the file that is created once all MPS daemons have been
+// started.
+const readyFilePath = "/mps/.ready"
+
 // Config represents a collection of config options for the device plugin.
 type Config struct {
 	configFile string
@@ -59,6 +73,7 @@ func main() {
 	}
 	c.Commands = []*cli.Command{
 		mount.NewCommand(),
+		newHealthCommand(config),
 	}
 
 	config.flags = []cli.Flag{
@@ -74,6 +89,13 @@ func main() {
 			Usage:   "the desired strategy for exposing MIG devices on GPUs that support it:\n\t\t[none | single | mixed]",
 			EnvVars: []string{"MIG_STRATEGY"},
 		},
+		&cli.StringFlag{
+			Name:    "driver-root-ctr-path",
+			Aliases: []string{"container-driver-root"},
+			Value:   spec.DefaultContainerDriverRoot,
+			Usage:   "the path where the NVIDIA driver root is mounted in the container; used to locate the NVML library and driver binaries",
+			EnvVars: []string{"DRIVER_ROOT_CTR_PATH", "CONTAINER_DRIVER_ROOT"},
+		},
 	}
 	c.Flags = config.flags
 
@@ -106,32 +128,81 @@ func (cfg *Config) loadConfig(c *cli.Context) (*spec.Config, error) {
 }
 
 func start(c *cli.Context, cfg *Config) error {
+	// ctx is cancelled once the daemons have been stopped on shutdown. It is
+	// passed to the daemons so that the commands that they run are aborted
+	// if the program exits.
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+
+	// Reap orphaned children, such as daemonized MPS control daemons, in
+	// case we are running as PID 1.
+	go reaper.Reap(ctx)
+
 	klog.Info("Starting OS watcher.")
 	sigs := watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	// Watch the config file so that changes are applied without waiting for
+	// a SIGHUP.
+	var configEvents <-chan fsnotify.Event
+	var configFile *watch.ConfigFile
+	if cfg.configFile != "" {
+		var err error
+		configFile, err = watch.NewConfigFile(cfg.configFile)
+		if err != nil {
+			klog.Warningf("Failed to watch config file %v; changes are applied on SIGHUP only: %v", cfg.configFile, err)
+		} else {
+			defer configFile.Close()
+			configEvents = configFile.Events
+		}
+	}
+
 	var started bool
 	var restartTimeout <-chan time.Time
 	var daemons []*mps.Daemon
+	stopChaos := func() {}
 restart:
 	// If we are restarting, stop daemons from previous run.
 	if started {
-		err := stopDaemons(daemons...)
+		stopChaos()
+		err := stopDaemons(ctx, daemons...)
 		if err != nil {
 			return fmt.Errorf("error stopping plugins from previous run: %v", err)
 		}
 	}
 
 	klog.Info("Starting Daemons.")
-	daemons, restartDaemons, err := startDaemons(c, cfg)
+	daemons, restartDaemons, err := startDaemons(ctx, c, cfg)
 	if err != nil {
 		return fmt.Errorf("error starting plugins: %v", err)
 	}
 	started = true
+	stopChaos = chaos.StartStopper("MPS daemon", stoppers(ctx, daemons...)...)
 
 	if restartDaemons {
 		klog.Infof("Failed to start one or more MPS deamons. Retrying in 30s...")
 		restartTimeout = time.After(30 * time.Second)
 	}
 
+	// reload applies the current config to the running daemons. A restart
+	// of all daemons is scheduled if a daemon fails to start.
+	reload := func() <-chan time.Time {
+		stopChaos()
+		defer func() {
+			stopChaos = chaos.StartStopper("MPS daemon", stoppers(ctx, daemons...)...)
+		}()
+
+		reloaded, restartDaemons, err := reloadDaemons(ctx, c, cfg, daemons)
+		if err != nil {
+			klog.Errorf("Failed to reload MPS daemons; keeping the running daemons: %v", err)
+		}
+		daemons = reloaded
+		if restartDaemons {
+			klog.Infof("Failed to start one or more MPS deamons. Retrying in 30s...")
+			return time.After(30 * time.Second)
+		}
+		return restartTimeout
+	}
+
 	// Start an infinite loop, waiting for several indicators to either log
 	// some messages, trigger a restart of the plugins, or exit the program.
 	for {
@@ -140,14 +211,42 @@ restart:
 		case <-restartTimeout:
 			goto restart
 
-		// Watch for any signals from the OS. On SIGHUP, restart this loop,
-		// restarting all of the plugins in the process. On all other
-		// signals, exit the loop and exit the program.
+		// Reload the daemons if the contents of the config file have
+		// changed.
+		case <-configEvents:
+			changed, err := configFile.Changed()
+			if err != nil {
+				klog.Warningf("Failed to check config file: %v", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			klog.Info("Config file changed, reloading.")
+			restartTimeout = reload()
+
+		// Watch for any signals from the OS. On SIGHUP, reload the config,
+		// restarting the daemons whose configuration has changed. On all
+		// other signals, exit the loop and exit the program.
 		case s := <-sigs:
 			switch s {
 			case syscall.SIGHUP:
-				klog.Info("Received SIGHUP, restarting.")
-				goto restart
+				// The config-manager sends a SIGHUP after updating the
+				// config file, which the config file watch also detects.
+				// The reload is skipped if the contents of the config
+				// file are unchanged since they were last checked so that
+				// the daemons are not reloaded twice for one change.
+				if configFile != nil {
+					changed, err := configFile.Changed()
+					if err != nil {
+						klog.Warningf("Failed to check config file: %v", err)
+					} else if !changed {
+						klog.Info("Received SIGHUP, but the config file is unchanged; skipping reload.")
+						continue
+					}
+				}
+				klog.Info("Received SIGHUP, reloading.")
+				restartTimeout = reload()
 			default:
 				klog.Infof("Received signal \"%v\", shutting down.", s)
 				goto exit
@@ -155,24 +254,113 @@ restart:
 		}
 	}
 exit:
-	if err := stopDaemons(daemons...); err != nil {
+	stopChaos()
+	if err := stopDaemons(ctx, daemons...); err != nil {
 		return fmt.Errorf("error stopping daemons: %v", err)
 	}
 	return nil
 }
 
-func startDaemons(c *cli.Context, cfg *Config) ([]*mps.Daemon, bool, error) {
+func startDaemons(ctx context.Context, c *cli.Context, cfg *Config) ([]*mps.Daemon, bool, error) {
+	mpsDaemons, config, err := cfg.getDaemons(c)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Print the config to the output.
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal config to JSON: %v", err)
+	}
+	klog.Infof("\nRunning with config:\n%v", string(configJSON))
+
+	if len(mpsDaemons) == 0 {
+		klog.Info("No devices are configured for MPS sharing; Waiting indefinitely.")
+	}
+
+	// Start all MPS daemons.
+	// If any daemon fails to start, all daemons are started again.
+	if err := startAll(ctx, mpsDaemons...); err != nil {
+		klog.Errorf("Failed to start MPS daemons: %v", err)
+		return mpsDaemons, true, nil
+	}
+	readyFile, err := os.Create(readyFilePath)
+	if err != nil {
+		return mpsDaemons, true, fmt.Errorf("failed to create .ready file")
+	}
+	defer readyFile.Close()
+
+	return mpsDaemons, false, nil
+}
+
+// reloadDaemons creates the daemons for the current configuration and
+// replaces the running daemons whose fingerprint has changed. Running daemons
+// that are unchanged are left untouched so that their clients are not
+// disconnected. If the configuration cannot be loaded, the running daemons are
+// kept.
+func reloadDaemons(ctx context.Context, c *cli.Context, cfg *Config, running []*mps.Daemon) ([]*mps.Daemon, bool, error) {
+	mpsDaemons, _, err := cfg.getDaemons(c)
+	if err != nil {
+		return running, false, err
+	}
+
+	byFingerprint := make(map[string]*mps.Daemon)
+	for _, d := range running {
+		byFingerprint[d.Fingerprint()] = d
+	}
+
+	kept := make(map[*mps.Daemon]bool)
+	var changed []*mps.Daemon
+	for i, d := range mpsDaemons {
+		if r, ok := byFingerprint[d.Fingerprint()]; ok && !kept[r] {
+			mpsDaemons[i] = r
+			kept[r] = true
+			continue
+		}
+		changed = append(changed, d)
+	}
+
+	// The running daemons that are not kept are stopped before the changed
+	// daemons are started since a daemon for the same resource uses the same
+	// pipe directory.
+	var stale []*mps.Daemon
+	for _, d := range running {
+		if !kept[d] {
+			stale = append(stale, d)
+		}
+	}
+	klog.Infof("Reloading MPS daemons: %d unchanged, %d stopped, %d started.", len(kept), len(stale), len(changed))
+	for _, d := range stale {
+		if err := d.Stop(ctx); err != nil {
+			klog.Warningf("Failed to stop MPS daemon for %v: %v", d.Resource(), err)
+		}
+	}
+
+	if err := startAll(ctx, changed...); err != nil {
+		klog.Errorf("Failed to start MPS daemons: %v", err)
+		return mpsDaemons, true, nil
+	}
+	return mpsDaemons, false, nil
+}
+
+// getDaemons loads the configuration and returns the MPS daemons required
+// for it together with the resulting config.
+func (cfg *Config) getDaemons(c *cli.Context) ([]*mps.Daemon, *spec.Config, error) {
 	// Load the configuration file
 	klog.Info("Loading configuration.")
 	config, err := cfg.loadConfig(c)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to load config: %v", err)
+		return nil, nil, fmt.Errorf("unable to load config: %v", err)
 	}
 	spec.DisableResourceNamingInConfig(config)
 
-	nvmllib := nvml.New()
+	driverRoot := driverroot.Root(*config.Flags.Plugin.ContainerDriverRoot)
+	nvmllib := nvml.New(
+		nvml.WithLibraryPath(driverRoot.TryResolveLibrary("libnvidia-ml.so.1")),
+	)
 	devicelib := device.New(nvmllib)
 	infolib := nvinfo.New(
+		nvinfo.WithRoot(string(driverRoot)),
 		nvinfo.WithNvmlLib(nvmllib),
 		nvinfo.WithDeviceLib(devicelib),
 	)
@@ -181,55 +369,77 @@ func startDaemons(c *cli.Context, cfg *Config) ([]*mps.Daemon, bool, error) {
 	klog.Info("Updating config with default resource matching patterns.")
 	err = rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to add default resources to config: %v", err)
+		return nil, nil, fmt.Errorf("unable to add default resources to config: %v", err)
 	}
 
-	// Print the config to the output.
-	configJSON, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to marshal config to JSON: %v", err)
-	}
-	klog.Infof("\nRunning with config:\n%v", string(configJSON))
-
 	// Get the set of daemons.
 	// Note that a daemon is only created for resources with at least one device.
 	klog.Info("Retrieving MPS daemons.")
 	mpsDaemons, err := mps.NewDaemons(infolib, nvmllib, devicelib,
 		mps.WithConfig(config),
+		mps.WithDriverRoot(driverRoot),
 	)
 	if err != nil {
-		return nil, false, fmt.Errorf("error getting daemons: %v", err)
+		return nil, nil, fmt.Errorf("error getting daemons: %v", err)
 	}
+	return mpsDaemons, config, nil
+}
 
-	if len(mpsDaemons) == 0 {
-		klog.Info("No devices are configured for MPS sharing; Waiting indefinitely.")
-	}
+// startAll starts the specified daemons concurrently, with at most
+// maxParallelDaemonStarts daemons being started at a time. Since starting a
+// daemon includes setting the compute mode of each of its devices, starting
+// daemons sequentially delays readiness on nodes with many resources.
+func startAll(ctx context.Context, mpsDaemons ...*mps.Daemon) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs error
 
-	// Loop through all MPS daemons and start them.
-	// If any daemon fails to start, all daemons are started again.
-	for _, mpsDaemon := range mpsDaemons {
-		if err := mpsDaemon.Start(); err != nil {
-			klog.Errorf("Failed to start MPS daemon: %v", err)
-			return mpsDaemons, true, nil
-		}
-	}
-	readyFile, err := os.Create("/mps/.ready")
-	if err != nil {
-		return mpsDaemons, true, fmt.Errorf("failed to create .ready file")
+	sem := make(chan struct{}, maxParallelDaemonStarts)
+	for _, d := range mpsDaemons {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := d.Start(ctx); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = errors.Join(errs, err)
+			}
+		}()
 	}
-	defer readyFile.Close()
+	wg.Wait()
 
-	return mpsDaemons, false, nil
+	return errs
 }
 
-func stopDaemons(mpsDaemons ...*mps.Daemon) error {
-	if err := os.Remove("/mps/.ready"); err != nil {
+func stopDaemons(ctx context.Context, mpsDaemons ...*mps.Daemon) error {
+	if err := os.Remove(readyFilePath); err != nil {
 		klog.Warningf("Failed to remove .ready file: %v", err)
 	}
 	klog.Info("Stopping MPS daemons.")
 	var errs error
 	for _, p := range mpsDaemons {
-		errs = errors.Join(errs, p.Stop())
+		errs = errors.Join(errs, p.Stop(ctx))
 	}
 	return errs
 }
+
+// stoppers returns functions that unexpectedly stop the MPS control daemon of
+// each of the specified daemons, either by killing it to simulate a crash or by
+// asking it to quit through its control pipe. These are used to inject faults
+// in chaos mode.
+func stoppers(ctx context.Context, mpsDaemons ...*mps.Daemon) []chaos.Stopper {
+	var stops []chaos.Stopper
+	for _, d := range mpsDaemons {
+		stops = append(stops, chaos.Stopper{
+			Quit: func() error {
+				_, err := d.EchoPipeToControl(ctx, "quit")
+				return err
+			},
+			Kill: d.Kill,
+		})
+	}
+	return stops
+}
@@ -18,16 +18,27 @@ package mps
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/opencontainers/selinux/go-selinux"
 	"k8s.io/klog/v2"
 
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
+	"github.com/NVIDIA/k8s-device-plugin/internal/reaper"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
@@ -35,6 +46,13 @@ type computeMode string
 
 const (
 	mpsControlBin = "nvidia-cuda-mps-control"
+	// mpsControlPIDFileName is the name of the file in the pipe directory to
+	// which the MPS control daemon writes its PID.
+	mpsControlPIDFileName = "nvidia-cuda-mps-control.pid"
+
+	// commandTimeout defines the maximum time that the MPS control and
+	// nvidia-smi commands invoked by the daemon may run for.
+	commandTimeout = 30 * time.Second
 
 	computeModeExclusiveProcess = computeMode("EXCLUSIVE_PROCESS")
 	computeModeDefault          = computeMode("DEFAULT")
@@ -53,14 +71,50 @@ type Daemon struct {
 	root Root
 	// logTailer tails the MPS control daemon logs.
 	logTailer *tailer
+	// driverRoot is the path at which the driver root is mounted. It is used
+	// to resolve the driver binaries invoked by the daemon.
+	driverRoot driverroot.Root
+	// pipeGroupID is the group that is granted access to the pipe directory.
+	// If this is not set, the pipe directory is accessible to all users.
+	pipeGroupID *uint32
 }
 
-// NewDaemon creates an MPS daemon instance.
-func NewDaemon(rm rm.ResourceManager, root Root) *Daemon {
+// NewDaemon creates an MPS daemon instance. The driver binaries invoked by the
+// daemon are resolved under the specified driver root if present.
+func NewDaemon(rm rm.ResourceManager, root Root, driverRoot driverroot.Root) *Daemon {
 	return &Daemon{
-		rm:   rm,
-		root: root,
+		rm:         rm,
+		root:       root,
+		driverRoot: driverRoot,
+	}
+}
+
+// Fingerprint identifies the resource, devices, and settings of the daemon. A
+// running daemon need not be restarted for a new daemon with the same
+// fingerprint.
+func (d *Daemon) Fingerprint() string {
+	ids := d.Devices().GetIDs()
+	slices.Sort(ids)
+
+	var pipeGroupID string
+	if d.pipeGroupID != nil {
+		pipeGroupID = strconv.FormatUint(uint64(*d.pipeGroupID), 10)
 	}
+	fingerprint := strings.Join([]string{
+		string(d.Resource()),
+		strings.Join(ids, ","),
+		string(d.root),
+		string(d.driverRoot),
+		pipeGroupID,
+	}, "\n")
+
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+// Resource returns the name of the resource shared using this MPS daemon.
+func (d *Daemon) Resource() spec.ResourceName {
+	return d.rm.Resource()
 }
 
 // Devices returns the list of devices under the control of this MPS daemon.
@@ -82,15 +136,22 @@ func (e envvars) toSlice() []string {
 // These should be passed to clients consuming the device shared using MPS.
 // TODO: Set CUDA_VISIBLE_DEVICES to include only the devices for this resource type.
 func (d *Daemon) EnvVars() envvars {
+	return daemonEnvVars(d.root, d.Resource())
+}
+
+// daemonEnvVars returns the environment variables for the MPS daemon of the
+// specified resource under the root.
+func daemonEnvVars(root Root, resource spec.ResourceName) envvars {
 	return map[string]string{
-		"CUDA_MPS_PIPE_DIRECTORY": d.PipeDir(),
-		"CUDA_MPS_LOG_DIRECTORY":  d.LogDir(),
+		"CUDA_MPS_PIPE_DIRECTORY": root.PipeDir(resource),
+		"CUDA_MPS_LOG_DIRECTORY":  root.LogDir(resource),
 	}
 }
 
-// Start starts the MPS deamon as a background process.
-func (d *Daemon) Start() error {
-	if err := d.setComputeMode(computeModeExclusiveProcess); err != nil {
+// Start starts the MPS deamon as a background process. The commands run to
+// start the daemon are aborted if the context is cancelled.
+func (d *Daemon) Start(ctx context.Context) error {
+	if err := d.setComputeMode(ctx, computeModeExclusiveProcess); err != nil {
 		return fmt.Errorf("error setting compute mode %v: %w", computeModeExclusiveProcess, err)
 	}
 
@@ -101,6 +162,10 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("error creating directory %v: %w", pipeDir, err)
 	}
 
+	if err := d.restrictPipeDir(pipeDir); err != nil {
+		return fmt.Errorf("error restricting access to %v: %w", pipeDir, err)
+	}
+
 	if err := setSELinuxContext(pipeDir, unprivilegedContainerSELinuxLabel); err != nil {
 		return fmt.Errorf("error setting SELinux context: %w", err)
 	}
@@ -110,20 +175,26 @@ func (d *Daemon) Start() error {
 		return fmt.Errorf("error creating directory %v: %w", logDir, err)
 	}
 
-	mpsDaemon := exec.Command(mpsControlBin, "-d")
+	// Client configurations that remain from a daemon that was not stopped
+	// cleanly refer to servers that no longer exist.
+	d.removeClientConfigs()
+
+	startCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	mpsDaemon := exec.CommandContext(startCtx, d.driverRoot.TryResolveBinary(mpsControlBin), "-d")
 	mpsDaemon.Env = append(mpsDaemon.Env, d.EnvVars().toSlice()...)
-	if err := mpsDaemon.Run(); err != nil {
+	if err := reaper.Run(mpsDaemon); err != nil {
 		return err
 	}
 
 	for index, limit := range d.perDevicePinnedDeviceMemoryLimits() {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
+		_, err := d.EchoPipeToControl(ctx, fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
 		if err != nil {
 			return fmt.Errorf("error setting pinned memory limit for device %v: %w", index, err)
 		}
 	}
 	if threadPercentage := d.activeThreadPercentage(); threadPercentage != "" {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
+		_, err := d.EchoPipeToControl(ctx, fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
 		if err != nil {
 			return fmt.Errorf("error setting active thread percentage: %w", err)
 		}
@@ -144,6 +215,20 @@ func (d *Daemon) Start() error {
 	return nil
 }
 
+// restrictPipeDir limits access to the pipe directory to the owner and the
+// configured pipe group. If no pipe group is configured, the directory is made
+// accessible to all users.
+func (d *Daemon) restrictPipeDir(pipeDir string) error {
+	if d.pipeGroupID == nil {
+		return os.Chmod(pipeDir, 0755)
+	}
+	klog.InfoS("Restricting MPS pipe directory to group", "resource", d.rm.Resource(), "gid", *d.pipeGroupID)
+	if err := os.Chown(pipeDir, -1, int(*d.pipeGroupID)); err != nil {
+		return err
+	}
+	return os.Chmod(pipeDir, 0770)
+}
+
 func setSELinuxContext(path string, context string) error {
 	_, err := os.Stat("/sys/fs/selinux")
 	if err != nil && errors.Is(err, os.ErrNotExist) {
@@ -157,9 +242,10 @@ func setSELinuxContext(path string, context string) error {
 	return selinux.Chcon(path, context, true)
 }
 
-// Stop ensures that the MPS daemon is quit.
-func (d *Daemon) Stop() error {
-	_, err := d.EchoPipeToControl("quit")
+// Stop ensures that the MPS daemon is quit. The commands run to stop the
+// daemon are aborted if the context is cancelled.
+func (d *Daemon) Stop(ctx context.Context) error {
+	_, err := d.EchoPipeToControl(ctx, "quit")
 	if err != nil {
 		return fmt.Errorf("error sending quit message: %w", err)
 	}
@@ -168,7 +254,7 @@ func (d *Daemon) Stop() error {
 	err = d.logTailer.Stop()
 	klog.InfoS("Stopped log tailer", "resource", d.rm.Resource(), "error", err)
 
-	if err := d.setComputeMode(computeModeDefault); err != nil {
+	if err := d.setComputeMode(ctx, computeModeDefault); err != nil {
 		return fmt.Errorf("error setting compute mode %v: %w", computeModeDefault, err)
 	}
 
@@ -181,9 +267,50 @@ func (d *Daemon) Stop() error {
 		klog.ErrorS(err, "Failed to remove pipe directory", "path", logDir)
 	}
 
+	d.removeClientConfigs()
+
 	return nil
 }
 
+// Kill sends SIGKILL to the MPS control daemon. Unlike Stop, this does not give
+// the daemon a chance to shut down its servers or clean up, and is used to
+// simulate a crash of the daemon.
+func (d *Daemon) Kill() error {
+	pid, err := readPIDFile(filepath.Join(d.PipeDir(), mpsControlPIDFileName))
+	if err != nil {
+		return fmt.Errorf("error getting MPS control daemon PID: %w", err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("error killing MPS control daemon %d: %w", pid, err)
+	}
+	klog.InfoS("Killed MPS control daemon", "resource", d.rm.Resource(), "pid", pid)
+	return nil
+}
+
+// readPIDFile reads the PID stored in the specified file.
+func readPIDFile(path string) (int, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID %q in %v", strings.TrimSpace(string(contents)), path)
+	}
+	return pid, nil
+}
+
+// removeClientConfigs removes the client configurations that the device
+// plugin wrote for the containers using the daemon. These contain the PIDs of
+// the MPS servers started by the daemon and are no longer valid once it has
+// stopped.
+func (d *Daemon) removeClientConfigs() {
+	clientConfigDir := d.root.ClientConfigDir(d.rm.Resource())
+	if err := os.RemoveAll(clientConfigDir); err != nil {
+		klog.ErrorS(err, "Failed to remove client config directory", "path", clientConfigDir)
+	}
+}
+
 func (d *Daemon) LogDir() string {
 	return d.root.LogDir(d.rm.Resource())
 }
@@ -201,25 +328,64 @@ func (d *Daemon) startedFile() string {
 }
 
 // AssertHealthy checks that the MPS control daemon is healthy.
-func (d *Daemon) AssertHealthy() error {
-	_, err := d.EchoPipeToControl("get_default_active_thread_percentage")
+func (d *Daemon) AssertHealthy(ctx context.Context) error {
+	return AssertHealthy(ctx, d.root, d.driverRoot, d.Resource())
+}
+
+// AssertHealthy checks that the MPS control daemon started for the specified
+// resource under the root is healthy. Since only the pipe directory of the
+// resource is required, this does not depend on the devices of the resource.
+func AssertHealthy(ctx context.Context, root Root, driverRoot driverroot.Root, resource spec.ResourceName) error {
+	_, err := echoPipeToControl(ctx, driverRoot, daemonEnvVars(root, resource), "get_default_active_thread_percentage")
 	return err
 }
 
+// ServerPIDs returns the PIDs of the MPS servers started by the control daemon.
+// A server is only started once the first client connects.
+func (d *Daemon) ServerPIDs(ctx context.Context) ([]int, error) {
+	out, err := d.EchoPipeToControl(ctx, "get_server_list")
+	if err != nil {
+		return nil, err
+	}
+	return parseServerList(out)
+}
+
+// parseServerList parses the whitespace-separated PIDs returned by the
+// get_server_list command.
+func parseServerList(out string) ([]int, error) {
+	pids := []int{}
+	for _, field := range strings.Fields(out) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server PID %q: %w", field, err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
 // EchoPipeToControl sends the specified command to the MPS control daemon.
-func (d *Daemon) EchoPipeToControl(command string) (string, error) {
+func (d *Daemon) EchoPipeToControl(ctx context.Context, command string) (string, error) {
+	return echoPipeToControl(ctx, d.driverRoot, d.EnvVars(), command)
+}
+
+// echoPipeToControl sends the specified command to the MPS control daemon
+// whose pipe directory is set in the specified environment.
+func echoPipeToControl(ctx context.Context, driverRoot driverroot.Root, env envvars, command string) (string, error) {
 	var out bytes.Buffer
 	reader, writer := io.Pipe()
 	defer writer.Close()
 	defer reader.Close()
 
-	mpsDaemon := exec.Command(mpsControlBin)
-	mpsDaemon.Env = append(mpsDaemon.Env, d.EnvVars().toSlice()...)
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	mpsDaemon := exec.CommandContext(ctx, driverRoot.TryResolveBinary(mpsControlBin))
+	mpsDaemon.Env = append(mpsDaemon.Env, env.toSlice()...)
 
 	mpsDaemon.Stdin = reader
 	mpsDaemon.Stdout = &out
 
-	if err := mpsDaemon.Start(); err != nil {
+	if err := reaper.Start(mpsDaemon); err != nil {
 		return "", fmt.Errorf("failed to start NVIDIA MPS command: %w", err)
 	}
 
@@ -228,19 +394,21 @@ func (d *Daemon) EchoPipeToControl(command string) (string, error) {
 	}
 	_ = writer.Close()
 
-	if err := mpsDaemon.Wait(); err != nil {
+	if err := reaper.Wait(mpsDaemon); err != nil {
 		return "", fmt.Errorf("failed to send command to MPS daemon: %w", err)
 	}
 	return out.String(), nil
 }
 
-func (d *Daemon) setComputeMode(mode computeMode) error {
+func (d *Daemon) setComputeMode(ctx context.Context, mode computeMode) error {
 	for _, uuid := range d.Devices().GetUUIDs() {
-		cmd := exec.Command(
-			"nvidia-smi",
+		cmdCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+		cmd := exec.CommandContext(cmdCtx,
+			d.driverRoot.TryResolveBinary("nvidia-smi"),
 			"-i", uuid,
 			"-c", string(mode))
-		output, err := cmd.CombinedOutput()
+		output, err := reaper.CombinedOutput(cmd)
+		cancel()
 		if err != nil {
 			klog.Errorf("\n%v", string(output))
 			return fmt.Errorf("error running nvidia-smi: %w", err)
@@ -270,11 +438,101 @@ func (m *Daemon) perDevicePinnedDeviceMemoryLimits() map[string]string {
 	return limits
 }
 
+// activeThreadPercentage returns the default active thread percentage for the
+// daemon. This is based on the GPU with the fewest replicas so that clients on
+// any GPU are not limited to less than their share of that GPU. Clients on
+// GPUs with more replicas are limited further by the percentage set for each
+// client. See ClientActiveThreadPercentage.
 func (m *Daemon) activeThreadPercentage() string {
-	if len(m.Devices()) == 0 {
+	minReplicas := 0
+	for _, replicas := range m.replicasPerGPU() {
+		if minReplicas == 0 || replicas < minReplicas {
+			minReplicas = replicas
+		}
+	}
+	if minReplicas == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", 100/minReplicas)
+}
+
+// ClientActiveThreadPercentage returns the active thread percentage for a
+// client that is allocated the specified device IDs. For each GPU, this is
+// the fraction of its replicas that are allocated to the client. Since the
+// percentage applies to all GPUs used by a client, the smallest fraction is
+// returned.
+func (m *Daemon) ClientActiveThreadPercentage(ids []string) string {
+	allocated := make(map[string]int)
+	for _, d := range m.Devices().Subset(ids) {
+		allocated[d.GetUUID()]++
+	}
+
+	replicasPerGPU := m.replicasPerGPU()
+	percentage := 0
+	for uuid, count := range allocated {
+		p := 100 * count / replicasPerGPU[uuid]
+		if percentage == 0 || p < percentage {
+			percentage = p
+		}
+	}
+	if percentage == 0 {
 		return ""
 	}
-	replicasPerDevice := len(m.Devices()) / len(m.Devices().GetUUIDs())
+	return fmt.Sprintf("%d", percentage)
+}
 
-	return fmt.Sprintf("%d", 100/replicasPerDevice)
+// ClientPinnedDeviceMemoryLimit returns the pinned device memory limit for a
+// client that is allocated the specified device IDs. For each GPU, the limit
+// is the fraction of its memory given by the replicas allocated to the client.
+// The limits are keyed by the ordinal of the GPU in the container, which
+// follows the order of the GPU indices on the host since only the allocated
+// GPUs are visible.
+func (m *Daemon) ClientPinnedDeviceMemoryLimit(ids []string) string {
+	allocated := make(map[string]uint64)
+	devices := make(map[string]*rm.Device)
+	for _, d := range m.Devices().Subset(ids) {
+		allocated[d.GetUUID()]++
+		devices[d.GetUUID()] = d
+	}
+
+	var uuids []string
+	for uuid := range devices {
+		uuids = append(uuids, uuid)
+	}
+	slices.SortFunc(uuids, func(a, b string) int {
+		return compareIndices(devices[a].Index, devices[b].Index)
+	})
+
+	replicasPerGPU := m.replicasPerGPU()
+	var limits []string
+	for ordinal, uuid := range uuids {
+		totalMemory := devices[uuid].TotalMemory
+		if totalMemory == 0 {
+			return ""
+		}
+		limit := totalMemory * allocated[uuid] / uint64(replicasPerGPU[uuid]) / 1024 / 1024
+		limits = append(limits, fmt.Sprintf("%d=%vM", ordinal, limit))
+	}
+	return strings.Join(limits, ",")
+}
+
+// compareIndices compares two device indices numerically, falling back to a
+// string comparison if either is not a number.
+func compareIndices(a, b string) int {
+	i, errA := strconv.Atoi(a)
+	j, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return i - j
+}
+
+// replicasPerGPU returns the number of replicas of each GPU keyed by UUID.
+// The number of replicas may differ between GPUs if replicasPerGiB is used.
+func (m *Daemon) replicasPerGPU() map[string]int {
+	replicas := make(map[string]int)
+	for _, d := range m.Devices() {
+		replicas[d.GetUUID()]++
+	}
+	return replicas
 }
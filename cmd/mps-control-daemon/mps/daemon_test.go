@@ -0,0 +1,320 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+// newTestDaemon creates a daemon for GPUs with the specified number of
+// replicas each.
+func newTestDaemon(replicasPerGPU map[string]int) *Daemon {
+	devices := make(rm.Devices)
+	for uuid, replicas := range replicasPerGPU {
+		for i := 0; i < replicas; i++ {
+			id := string(rm.NewAnnotatedID(uuid, i))
+			devices[id] = &rm.Device{Device: pluginapi.Device{ID: id}, Replicas: replicas}
+		}
+	}
+	return NewDaemon(&rm.ResourceManagerMock{
+		DevicesFunc: func() rm.Devices {
+			return devices
+		},
+	}, ContainerRoot, "")
+}
+
+func TestActiveThreadPercentage(t *testing.T) {
+	testCases := []struct {
+		description                    string
+		replicasPerGPU                 map[string]int
+		requestIDs                     []string
+		expectedDefault                string
+		expectedClientThreadPercentage string
+	}{
+		{
+			description:                    "no devices",
+			replicasPerGPU:                 map[string]int{},
+			expectedDefault:                "",
+			expectedClientThreadPercentage: "",
+		},
+		{
+			description:                    "single GPU",
+			replicasPerGPU:                 map[string]int{"GPU-0": 4},
+			requestIDs:                     []string{"GPU-0::1"},
+			expectedDefault:                "25",
+			expectedClientThreadPercentage: "25",
+		},
+		{
+			description:                    "GPUs with different replicas",
+			replicasPerGPU:                 map[string]int{"GPU-0": 2, "GPU-1": 8},
+			requestIDs:                     []string{"GPU-1::3"},
+			expectedDefault:                "50",
+			expectedClientThreadPercentage: "12",
+		},
+		{
+			description:                    "multiple replicas of a GPU",
+			replicasPerGPU:                 map[string]int{"GPU-0": 4},
+			requestIDs:                     []string{"GPU-0::0", "GPU-0::1"},
+			expectedDefault:                "25",
+			expectedClientThreadPercentage: "50",
+		},
+		{
+			description:                    "smallest fraction across GPUs",
+			replicasPerGPU:                 map[string]int{"GPU-0": 2, "GPU-1": 4},
+			requestIDs:                     []string{"GPU-0::0", "GPU-1::0"},
+			expectedDefault:                "50",
+			expectedClientThreadPercentage: "25",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			d := newTestDaemon(tc.replicasPerGPU)
+			require.Equal(t, tc.expectedDefault, d.activeThreadPercentage())
+			require.Equal(t, tc.expectedClientThreadPercentage, d.ClientActiveThreadPercentage(tc.requestIDs))
+		})
+	}
+}
+
+func TestClientPinnedDeviceMemoryLimit(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	gpus := []struct {
+		uuid        string
+		index       string
+		replicas    int
+		totalMemory uint64
+	}{
+		{uuid: "GPU-0", index: "0", replicas: 4, totalMemory: 16 * gib},
+		{uuid: "GPU-1", index: "1", replicas: 2, totalMemory: 16 * gib},
+		{uuid: "GPU-10", index: "10", replicas: 2, totalMemory: 8 * gib},
+	}
+	devices := make(rm.Devices)
+	for _, gpu := range gpus {
+		for i := 0; i < gpu.replicas; i++ {
+			id := string(rm.NewAnnotatedID(gpu.uuid, i))
+			devices[id] = &rm.Device{
+				Device:      pluginapi.Device{ID: id},
+				Index:       gpu.index,
+				TotalMemory: gpu.totalMemory,
+				Replicas:    gpu.replicas,
+			}
+		}
+	}
+	d := NewDaemon(&rm.ResourceManagerMock{
+		DevicesFunc: func() rm.Devices {
+			return devices
+		},
+	}, ContainerRoot, "")
+
+	testCases := []struct {
+		description   string
+		requestIDs    []string
+		expectedLimit string
+	}{
+		{
+			description:   "no devices",
+			expectedLimit: "",
+		},
+		{
+			description:   "single replica",
+			requestIDs:    []string{"GPU-0::1"},
+			expectedLimit: "0=4096M",
+		},
+		{
+			description:   "multiple replicas of a GPU",
+			requestIDs:    []string{"GPU-0::0", "GPU-0::3"},
+			expectedLimit: "0=8192M",
+		},
+		{
+			description:   "ordinals follow host indices",
+			requestIDs:    []string{"GPU-10::0", "GPU-1::0", "GPU-1::1"},
+			expectedLimit: "0=16384M,1=4096M",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expectedLimit, d.ClientPinnedDeviceMemoryLimit(tc.requestIDs))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	newDaemon := func(resource spec.ResourceName, pipeGroupID *uint32, ids ...string) *Daemon {
+		devices := make(rm.Devices)
+		for _, id := range ids {
+			devices[id] = &rm.Device{Device: pluginapi.Device{ID: id}}
+		}
+		d := NewDaemon(&rm.ResourceManagerMock{
+			ResourceFunc: func() spec.ResourceName { return resource },
+			DevicesFunc:  func() rm.Devices { return devices },
+		}, ContainerRoot, "")
+		d.pipeGroupID = pipeGroupID
+		return d
+	}
+	gid := uint32(2000)
+
+	base := newDaemon("nvidia.com/gpu", nil, "GPU-0::0", "GPU-0::1")
+
+	testCases := []struct {
+		description string
+		daemon      *Daemon
+		expectEqual bool
+	}{
+		{
+			description: "identical daemon",
+			daemon:      newDaemon("nvidia.com/gpu", nil, "GPU-0::1", "GPU-0::0"),
+			expectEqual: true,
+		},
+		{
+			description: "devices changed",
+			daemon:      newDaemon("nvidia.com/gpu", nil, "GPU-0::0", "GPU-0::1", "GPU-0::2"),
+		},
+		{
+			description: "resource changed",
+			daemon:      newDaemon("nvidia.com/gpu.shared", nil, "GPU-0::0", "GPU-0::1"),
+		},
+		{
+			description: "pipe group changed",
+			daemon:      newDaemon("nvidia.com/gpu", &gid, "GPU-0::0", "GPU-0::1"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.expectEqual {
+				require.Equal(t, base.Fingerprint(), tc.daemon.Fingerprint())
+				return
+			}
+			require.NotEqual(t, base.Fingerprint(), tc.daemon.Fingerprint())
+		})
+	}
+}
+
+func TestParseServerList(t *testing.T) {
+	testCases := []struct {
+		description   string
+		output        string
+		expectedPIDs  []int
+		expectedError bool
+	}{
+		{
+			description:  "no servers",
+			output:       "",
+			expectedPIDs: []int{},
+		},
+		{
+			description:  "single server",
+			output:       "1234\n",
+			expectedPIDs: []int{1234},
+		},
+		{
+			description:  "multiple servers",
+			output:       "1234\n5678\n",
+			expectedPIDs: []int{1234, 5678},
+		},
+		{
+			description:   "invalid output",
+			output:        "Cannot find MPS control daemon process\n",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pids, err := parseServerList(tc.output)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPIDs, pids)
+		})
+	}
+}
+
+func TestRemoveClientConfigs(t *testing.T) {
+	root := Root(t.TempDir())
+	for _, resource := range []spec.ResourceName{"nvidia.com/gpu", "nvidia.com/gpu.shared"} {
+		require.NoError(t, os.MkdirAll(root.ClientConfigDir(resource), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root.ClientConfigDir(resource), "config.json"), nil, 0644))
+	}
+
+	d := NewDaemon(&rm.ResourceManagerMock{
+		ResourceFunc: func() spec.ResourceName {
+			return "nvidia.com/gpu.shared"
+		},
+	}, root, "")
+	d.removeClientConfigs()
+
+	require.NoDirExists(t, root.ClientConfigDir("nvidia.com/gpu.shared"))
+	require.FileExists(t, filepath.Join(root.ClientConfigDir("nvidia.com/gpu"), "config.json"))
+}
+
+func TestReadPIDFile(t *testing.T) {
+	testCases := []struct {
+		description   string
+		contents      string
+		expectedPID   int
+		expectedError bool
+	}{
+		{
+			description:   "missing file",
+			expectedError: true,
+		},
+		{
+			description: "valid PID",
+			contents:    "1234\n",
+			expectedPID: 1234,
+		},
+		{
+			description:   "invalid PID",
+			contents:      "daemon",
+			expectedError: true,
+		},
+		{
+			description:   "non-positive PID",
+			contents:      "0",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), mpsControlPIDFileName)
+			if tc.contents != "" {
+				require.NoError(t, os.WriteFile(path, []byte(tc.contents), 0644))
+			}
+
+			pid, err := readPIDFile(path)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPID, pid)
+		})
+	}
+}
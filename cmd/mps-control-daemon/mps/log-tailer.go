@@ -20,6 +20,8 @@ import (
 	"context"
 	"os"
 	"os/exec"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/reaper"
 )
 
 // tailer tails the contents of a file.
@@ -46,7 +48,7 @@ func (t *tailer) Start() error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Start(); err != nil {
+	if err := reaper.Start(cmd); err != nil {
 		return err
 	}
 	t.cmd = cmd
@@ -65,5 +67,5 @@ func (t *tailer) Stop() error {
 		return nil
 	}
 
-	return t.cmd.Wait()
+	return reaper.Wait(t.cmd)
 }
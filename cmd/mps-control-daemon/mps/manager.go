@@ -25,6 +25,7 @@ import (
 	"k8s.io/klog/v2"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
@@ -33,10 +34,11 @@ type Manager interface {
 }
 
 type manager struct {
-	infolib   info.Interface
-	nvmllib   nvml.Interface
-	devicelib device.Interface
-	config    *spec.Config
+	infolib    info.Interface
+	nvmllib    nvml.Interface
+	devicelib  device.Interface
+	config     *spec.Config
+	driverRoot driverroot.Root
 }
 
 type nullManager struct{}
@@ -98,13 +100,26 @@ func (m *manager) Daemons() ([]*Daemon, error) {
 				return nil, fmt.Errorf("invalid MPS configuration: %w", err)
 			}
 		}
-		daemon := NewDaemon(resourceManager, ContainerRoot)
+		daemon := NewDaemon(resourceManager, ContainerRoot, m.driverRoot)
+		daemon.pipeGroupID = m.pipeGroupID(resourceManager.Resource())
 		daemons = append(daemons, daemon)
 	}
 
 	return daemons, nil
 }
 
+// pipeGroupID returns the pipe group configured for the specified resource.
+// Since MPS resources may be renamed, the configured name, the configured
+// rename, and the default shared name are matched.
+func (m *manager) pipeGroupID(resource spec.ResourceName) *uint32 {
+	for _, r := range m.config.Sharing.MPS.Resources {
+		if r.Name == resource || r.Rename == resource || r.Name.DefaultSharedRename() == resource {
+			return r.PipeGroupID
+		}
+	}
+	return nil
+}
+
 // Daemons always returns an empty slice for a nullManager.
 func (m *nullManager) Daemons() ([]*Daemon, error) {
 	return nil, nil
@@ -0,0 +1,72 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestPipeGroupID(t *testing.T) {
+	gid := uint32(2000)
+	m := &manager{
+		config: &spec.Config{
+			Sharing: spec.Sharing{
+				MPS: &spec.ReplicatedResources{
+					Resources: []spec.ReplicatedResource{
+						{Name: "nvidia.com/gpu", Rename: "nvidia.com/gpu.mps", PipeGroupID: &gid},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		description string
+		resource    spec.ResourceName
+		expected    *uint32
+	}{
+		{
+			description: "configured name",
+			resource:    "nvidia.com/gpu",
+			expected:    &gid,
+		},
+		{
+			description: "default shared name",
+			resource:    "nvidia.com/gpu.shared",
+			expected:    &gid,
+		},
+		{
+			description: "renamed resource",
+			resource:    "nvidia.com/gpu.mps",
+			expected:    &gid,
+		},
+		{
+			description: "unconfigured resource",
+			resource:    "nvidia.com/other",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, m.pipeGroupID(tc.resource))
+		})
+	}
+}
@@ -18,6 +18,7 @@ package mps
 
 import (
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 )
 
 // Option defines a functional option for configuring an MPS manager.
@@ -29,3 +30,11 @@ func WithConfig(config *spec.Config) Option {
 		m.config = config
 	}
 }
+
+// WithDriverRoot sets the path at which the driver root is mounted. The MPS
+// control daemon and nvidia-smi are resolved under this path if present.
+func WithDriverRoot(root driverroot.Root) Option {
+	return func(m *manager) {
+		m.driverRoot = root
+	}
+}
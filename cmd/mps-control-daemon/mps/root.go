@@ -17,6 +17,7 @@
 package mps
 
 import (
+	"io/fs"
 	"path/filepath"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
@@ -24,6 +25,10 @@ import (
 
 const (
 	ContainerRoot = Root("/mps")
+
+	// startedFileName is the name of the per-resource file that is created
+	// once the MPS daemon for the resource has been started.
+	startedFileName = ".started"
 )
 
 // Root represents an MPS root.
@@ -47,9 +52,45 @@ func (r Root) ShmDir(resourceName spec.ResourceName) string {
 	return r.Path("shm")
 }
 
+// ClientConfigDir returns the per-resource directory in which the client
+// configurations of allocated containers are stored.
+func (r Root) ClientConfigDir(resourceName spec.ResourceName) string {
+	return r.Path(string(resourceName), "clients")
+}
+
+// ClientConfigFile returns the per-resource path at which the client
+// configuration is made available to a container.
+func (r Root) ClientConfigFile(resourceName spec.ResourceName) string {
+	return r.Path(string(resourceName), "client.json")
+}
+
 // startedFile returns the per-resource .started file name for the specified root.
 func (r Root) startedFile(resourceName spec.ResourceName) string {
-	return r.Path(string(resourceName), ".started")
+	return r.Path(string(resourceName), startedFileName)
+}
+
+// StartedResources returns the resources for which an MPS daemon has been
+// started under the root.
+func (r Root) StartedResources() ([]spec.ResourceName, error) {
+	var resources []spec.ResourceName
+	err := filepath.WalkDir(string(r), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != startedFileName {
+			return nil
+		}
+		resource, err := filepath.Rel(string(r), filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		resources = append(resources, spec.ResourceName(filepath.ToSlash(resource)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
 }
 
 // Path returns a path relative to the MPS root.
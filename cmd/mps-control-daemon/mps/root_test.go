@@ -0,0 +1,40 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package mps
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestStartedResources(t *testing.T) {
+	root := Root(t.TempDir())
+	for _, resource := range []spec.ResourceName{"nvidia.com/gpu", "nvidia.com/gpu.shared"} {
+		require.NoError(t, os.MkdirAll(root.LogDir(resource), 0755))
+	}
+	for _, resource := range []spec.ResourceName{"nvidia.com/gpu.shared"} {
+		require.NoError(t, os.WriteFile(root.startedFile(resource), nil, 0644))
+	}
+
+	resources, err := root.StartedResources()
+	require.NoError(t, err)
+	require.Equal(t, []spec.ResourceName{"nvidia.com/gpu.shared"}, resources)
+}
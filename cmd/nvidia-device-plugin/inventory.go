@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// deviceInventory tracks the set of GPUs present on the node so that GPUs
+// that are hot-added or removed can be detected.
+type deviceInventory struct {
+	nvmllib nvml.Interface
+	uuids   []string
+}
+
+// newDeviceInventory creates an inventory of the GPUs currently on the node.
+func newDeviceInventory(nvmllib nvml.Interface) (*deviceInventory, error) {
+	uuids, err := getGPUUUIDs(nvmllib)
+	if err != nil {
+		return nil, err
+	}
+	return &deviceInventory{
+		nvmllib: nvmllib,
+		uuids:   uuids,
+	}, nil
+}
+
+// changed checks whether the set of GPUs on the node differs from the set
+// that was present when the inventory was created.
+func (i *deviceInventory) changed() (bool, error) {
+	if i == nil {
+		return false, nil
+	}
+	uuids, err := getGPUUUIDs(i.nvmllib)
+	if err != nil {
+		return false, err
+	}
+	if slices.Equal(i.uuids, uuids) {
+		return false, nil
+	}
+	for _, uuid := range uuids {
+		if !slices.Contains(i.uuids, uuid) {
+			klog.Infof("GPU %v was added", uuid)
+		}
+	}
+	for _, uuid := range i.uuids {
+		if !slices.Contains(uuids, uuid) {
+			klog.Infof("GPU %v was removed", uuid)
+		}
+	}
+	return true, nil
+}
+
+// getGPUUUIDs returns the sorted UUIDs of the GPUs visible to NVML.
+func getGPUUUIDs(nvmllib nvml.Interface) ([]string, error) {
+	if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		_ = nvmllib.Shutdown()
+	}()
+
+	count, ret := nvmllib.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to get device count: %v", ret)
+	}
+	var uuids []string
+	for i := 0; i < count; i++ {
+		device, ret := nvmllib.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get device %d: %v", i, ret)
+		}
+		uuid, ret := device.GetUUID()
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get UUID of device %d: %v", i, ret)
+		}
+		uuids = append(uuids, uuid)
+	}
+	slices.Sort(uuids)
+	return uuids, nil
+}
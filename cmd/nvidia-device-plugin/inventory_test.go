@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+type inventoryTestNVML struct {
+	nvml.Interface
+	uuids []string
+}
+
+type inventoryTestDevice struct {
+	nvml.Device
+	uuid string
+}
+
+func (n *inventoryTestNVML) Init() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (n *inventoryTestNVML) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (n *inventoryTestNVML) DeviceGetCount() (int, nvml.Return) {
+	return len(n.uuids), nvml.SUCCESS
+}
+
+func (n *inventoryTestNVML) DeviceGetHandleByIndex(i int) (nvml.Device, nvml.Return) {
+	return &inventoryTestDevice{uuid: n.uuids[i]}, nvml.SUCCESS
+}
+
+func (d *inventoryTestDevice) GetUUID() (string, nvml.Return) {
+	return d.uuid, nvml.SUCCESS
+}
+
+func TestDeviceInventoryChanged(t *testing.T) {
+	testCases := []struct {
+		description     string
+		initial         []string
+		current         []string
+		expectedChanged bool
+	}{
+		{
+			description: "unchanged",
+			initial:     []string{"GPU-0", "GPU-1"},
+			current:     []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description: "order is ignored",
+			initial:     []string{"GPU-0", "GPU-1"},
+			current:     []string{"GPU-1", "GPU-0"},
+		},
+		{
+			description:     "GPU added",
+			initial:         []string{"GPU-0"},
+			current:         []string{"GPU-0", "GPU-1"},
+			expectedChanged: true,
+		},
+		{
+			description:     "GPU removed",
+			initial:         []string{"GPU-0", "GPU-1"},
+			current:         []string{"GPU-1"},
+			expectedChanged: true,
+		},
+		{
+			description:     "GPU replaced",
+			initial:         []string{"GPU-0"},
+			current:         []string{"GPU-1"},
+			expectedChanged: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			nvmllib := &inventoryTestNVML{uuids: tc.initial}
+			inventory, err := newDeviceInventory(nvmllib)
+			require.NoError(t, err)
+
+			nvmllib.uuids = tc.current
+			changed, err := inventory.changed()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedChanged, changed)
+		})
+	}
+}
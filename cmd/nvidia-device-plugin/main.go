@@ -17,9 +17,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -34,8 +36,10 @@ import (
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 	"github.com/NVIDIA/k8s-device-plugin/internal/info"
 	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/reaper"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 	"github.com/NVIDIA/k8s-device-plugin/internal/watch"
 )
@@ -45,6 +49,12 @@ type options struct {
 	configFile      string
 	kubeletSocket   string
 	cdiFeatureFlags cli.StringSlice
+	// inventoryInterval is the interval at which the set of GPUs on the node
+	// is checked for changes.
+	inventoryInterval time.Duration
+	// metricsAddress is the address on which the metrics of the plugins are
+	// served. If this is empty, the metrics are not served.
+	metricsAddress string
 }
 
 func main() {
@@ -150,6 +160,70 @@ func main() {
 			Usage:   "the strategy to use to discover devices: 'auto', 'nvml', or 'tegra'",
 			EnvVars: []string{"DEVICE_DISCOVERY_STRATEGY"},
 		},
+		&cli.BoolFlag{
+			Name:    "nccl-topology-hints",
+			Usage:   "set NCCL envvars describing the link topology of the allocated GPUs for multi-GPU allocations",
+			EnvVars: []string{"NCCL_TOPOLOGY_HINTS"},
+		},
+		&cli.StringFlag{
+			Name:    "pre-allocate-hook",
+			Usage:   "the path to an executable that is run with the allocation context as JSON on stdin before each Allocate; a failure fails the allocation",
+			EnvVars: []string{"PRE_ALLOCATE_HOOK"},
+		},
+		&cli.StringFlag{
+			Name:    "post-allocate-hook",
+			Usage:   "the path to an executable that is run with the allocation context and response as JSON on stdin after each Allocate; a failure fails the allocation",
+			EnvVars: []string{"POST_ALLOCATE_HOOK"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "preserve-env",
+			Usage:   "a list of envvars that the plugin does not set so that values from the container spec are used; entries ending in '*' match by prefix. Envvars controlling device visibility are always set",
+			EnvVars: []string{"PRESERVE_ENV"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "allocation-policy",
+			Usage:   "the ordered list of policies used to determine preferred allocations; policies that do not apply or fail fall back to the next:\n\t\t[aligned | distributed | first-fit]",
+			EnvVars: []string{"ALLOCATION_POLICY"},
+		},
+		&cli.StringFlag{
+			Name:    "canary-allocation-policy",
+			Usage:   "a policy whose preferred allocations are computed and logged if they differ from those returned to the kubelet:\n\t\t[aligned | distributed | first-fit]",
+			EnvVars: []string{"CANARY_ALLOCATION_POLICY"},
+		},
+		&cli.GenericFlag{
+			Name:    "allocate-memory-wait",
+			Value:   spec.NewDurationValue(0),
+			Usage:   "how long an allocation of replicated devices waits for the memory of the requested replicas to be freed before failing; 0 disables the wait",
+			EnvVars: []string{"ALLOCATE_MEMORY_WAIT"},
+		},
+		&cli.GenericFlag{
+			Name:    "fabric-ready-timeout",
+			Value:   spec.NewDurationValue(5 * time.Minute),
+			Usage:   "how long the GPU fabric may take to become ready on NVSwitch systems before this is reported as a failure; devices remain unhealthy until the fabric is ready",
+			EnvVars: []string{"FABRIC_READY_TIMEOUT"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "throttle-health-checks",
+			Usage:   "the clock throttle reasons that mark the devices on a GPU as unhealthy while it is throttled for longer than 5 minutes:\n\t\t[thermal | power]",
+			EnvVars: []string{"THROTTLE_HEALTH_CHECKS", "DP_THROTTLE_HEALTHCHECKS"},
+		},
+		&cli.Float64Flag{
+			Name:    "throttled-capacity",
+			Usage:   "the fraction [0, 1) of the devices on a throttled GPU that remain healthy",
+			EnvVars: []string{"THROTTLED_CAPACITY", "DP_THROTTLED_CAPACITY"},
+		},
+		&cli.GenericFlag{
+			Name:    "mps-health-check-interval",
+			Value:   spec.NewDurationValue(30 * time.Second),
+			Usage:   "how often the MPS control daemon of each resource shared using MPS is probed",
+			EnvVars: []string{"MPS_HEALTH_CHECK_INTERVAL"},
+		},
+		&cli.GenericFlag{
+			Name:    "mps-degraded-latency",
+			Value:   spec.NewDurationValue(2 * time.Second),
+			Usage:   "the probe latency above which an MPS control daemon is considered degraded and the devices shared using it are marked as unhealthy",
+			EnvVars: []string{"MPS_DEGRADED_LATENCY"},
+		},
 		&cli.IntSliceFlag{
 			Name:    "imex-channel-ids",
 			Usage:   "A list of IMEX channels to inject.",
@@ -168,6 +242,19 @@ func main() {
 			Destination: &o.kubeletSocket,
 			EnvVars:     []string{"KUBELET_SOCKET"},
 		},
+		&cli.DurationFlag{
+			Name:        "device-inventory-interval",
+			Value:       time.Minute,
+			Usage:       "the interval at which to check for GPUs that are added to or removed from the node; the plugins are restarted if the set of GPUs changes. A value of 0 disables the check",
+			Destination: &o.inventoryInterval,
+			EnvVars:     []string{"DEVICE_INVENTORY_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:        "metrics-address",
+			Usage:       "the address on which the metrics of the plugins are served at /metrics; if this is empty, the metrics are not served",
+			Destination: &o.metricsAddress,
+			EnvVars:     []string{"METRICS_ADDRESS"},
+		},
 		&cli.StringFlag{
 			Name:        "config-file",
 			Usage:       "the path to a config file as an alternative to command line options or environment variables",
@@ -214,6 +301,59 @@ func validateFlags(infolib nvinfo.Interface, config *spec.Config) error {
 		}
 	}
 
+	if config.Flags.Plugin.AllocationPolicies != nil {
+		for _, policy := range *config.Flags.Plugin.AllocationPolicies {
+			switch policy {
+			case spec.AllocationPolicyAligned:
+			case spec.AllocationPolicyDistributed:
+			case spec.AllocationPolicyFirstFit:
+			default:
+				return fmt.Errorf("invalid --allocation-policy option: %v", policy)
+			}
+		}
+	}
+
+	if policy := config.Flags.Plugin.CanaryAllocationPolicy; policy != nil && *policy != "" {
+		switch *policy {
+		case spec.AllocationPolicyAligned:
+		case spec.AllocationPolicyDistributed:
+		case spec.AllocationPolicyFirstFit:
+		default:
+			return fmt.Errorf("invalid --canary-allocation-policy option: %v", *policy)
+		}
+	}
+
+	if wait := config.Flags.Plugin.AllocateMemoryWait; wait != nil && (*wait < 0 || wait.IsInfinite()) {
+		return fmt.Errorf("invalid --allocate-memory-wait option: %v", *wait)
+	}
+
+	if timeout := config.Flags.Plugin.FabricReadyTimeout; timeout != nil && *timeout < 0 {
+		return fmt.Errorf("invalid --fabric-ready-timeout option: %v", *timeout)
+	}
+
+	if config.Flags.Plugin.ThrottleHealthChecks != nil {
+		for _, reason := range *config.Flags.Plugin.ThrottleHealthChecks {
+			switch reason {
+			case spec.ThrottleReasonThermal:
+			case spec.ThrottleReasonPower:
+			default:
+				return fmt.Errorf("invalid --throttle-health-checks option: %v", reason)
+			}
+		}
+	}
+
+	if capacity := config.Flags.Plugin.ThrottledCapacity; capacity != nil && (*capacity < 0 || *capacity >= 1) {
+		return fmt.Errorf("invalid --throttled-capacity option: %v", *capacity)
+	}
+
+	if interval := config.Flags.Plugin.MpsHealthCheckInterval; interval != nil && (*interval <= 0 || interval.IsInfinite()) {
+		return fmt.Errorf("invalid --mps-health-check-interval option: %v", *interval)
+	}
+
+	if latency := config.Flags.Plugin.MpsDegradedLatency; latency != nil && *latency <= 0 {
+		return fmt.Errorf("invalid --mps-degraded-latency option: %v", *latency)
+	}
+
 	switch *config.Flags.DeviceDiscoveryStrategy {
 	case "auto":
 	case "nvml":
@@ -257,23 +397,82 @@ func start(c *cli.Context, o *options) error {
 	}
 	defer watcher.Close()
 
+	// ctx is cancelled once the plugins have been stopped on shutdown.
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+
+	// Reap orphaned children, such as those of allocate hooks, in
+	// case we are running as PID 1.
+	go reaper.Reap(ctx)
+
+	if o.metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", plugin.MetricsHandler())
+		server := &http.Server{
+			Addr:              o.metricsAddress,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		klog.Infof("Serving metrics on %v", o.metricsAddress)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				klog.Errorf("Failed to serve metrics: %v", err)
+			}
+		}()
+		defer server.Close()
+	}
+
 	klog.Info("Starting OS watcher.")
 	sigs := watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
 	var started bool
 	var restartTimeout <-chan time.Time
 	var plugins []plugin.Interface
+	// Each set of plugins is associated with a context that is cancelled
+	// after the plugins are stopped. This ensures that requests made by the
+	// plugins of a previous run, such as registering with the kubelet, are
+	// aborted. The plugins started by a reload use a separate context that is
+	// cancelled when the next reload starts, since a plugin only uses its
+	// context while it is being started.
+	stopRun := func() {}
+	stopReload := func() {}
+
+	var inventoryTicks <-chan time.Time
+	if o.inventoryInterval > 0 {
+		ticker := time.NewTicker(o.inventoryInterval)
+		defer ticker.Stop()
+		inventoryTicks = ticker.C
+	}
+
+	// Watch the config file so that changes are applied without waiting for
+	// a SIGHUP.
+	var configEvents <-chan fsnotify.Event
+	var configFile *watch.ConfigFile
+	if o.configFile != "" {
+		configFile, err = watch.NewConfigFile(o.configFile)
+		if err != nil {
+			klog.Warningf("Failed to watch config file %v; changes are applied on SIGHUP only: %v", o.configFile, err)
+		} else {
+			defer configFile.Close()
+			configEvents = configFile.Events
+		}
+	}
 restart:
 	// If we are restarting, stop plugins from previous run.
 	if started {
 		err := stopPlugins(plugins)
+		stopRun()
+		stopReload()
 		if err != nil {
 			return fmt.Errorf("error stopping plugins from previous run: %v", err)
 		}
 	}
 
+	runCtx, stopRunCtx := context.WithCancel(ctx)
+	stopRun = stopRunCtx
+
 	klog.Info("Starting Plugins.")
-	plugins, restartPlugins, err := startPlugins(c, o)
+	plugins, inventory, restartPlugins, err := startPlugins(runCtx, c, o)
 	if err != nil {
 		return fmt.Errorf("error starting plugins: %v", err)
 	}
@@ -284,6 +483,28 @@ restart:
 		restartTimeout = time.After(30 * time.Second)
 	}
 
+	// reload applies the current config to the running plugins. A restart
+	// of all plugins is scheduled if a plugin fails to start.
+	reload := func() <-chan time.Time {
+		stopReload()
+		reloadCtx, stopReloadCtx := context.WithCancel(ctx)
+		stopReload = stopReloadCtx
+
+		reloaded, reloadedInventory, restartPlugins, err := reloadPlugins(reloadCtx, c, o, plugins)
+		if err != nil {
+			klog.Errorf("Failed to reload plugins; keeping the running plugins: %v", err)
+		}
+		plugins = reloaded
+		if reloadedInventory != nil {
+			inventory = reloadedInventory
+		}
+		if restartPlugins {
+			klog.Infof("Failed to start one or more plugins. Retrying in 30s...")
+			return time.After(30 * time.Second)
+		}
+		return restartTimeout
+	}
+
 	// Start an infinite loop, waiting for several indicators to either log
 	// some messages, trigger a restart of the plugins, or exit the program.
 	for {
@@ -305,14 +526,56 @@ restart:
 		case err := <-watcher.Errors:
 			klog.Infof("inotify: %s", err)
 
-		// Watch for any signals from the OS. On SIGHUP, restart this loop,
-		// restarting all of the plugins in the process. On all other
-		// signals, exit the loop and exit the program.
+		// Check whether GPUs have been added to or removed from the node.
+		// If so, restart the plugins so that the set of resources
+		// advertised to the kubelet is updated.
+		case <-inventoryTicks:
+			changed, err := inventory.changed()
+			if err != nil {
+				klog.Warningf("Failed to check device inventory: %v", err)
+				continue
+			}
+			if changed {
+				klog.Info("Device inventory changed, restarting.")
+				goto restart
+			}
+
+		// Reload the plugins if the contents of the config file have
+		// changed.
+		case <-configEvents:
+			changed, err := configFile.Changed()
+			if err != nil {
+				klog.Warningf("Failed to check config file: %v", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			klog.Info("Config file changed, reloading.")
+			restartTimeout = reload()
+
+		// Watch for any signals from the OS. On SIGHUP, reload the config,
+		// restarting the plugins whose configuration has changed. On all
+		// other signals, exit the loop and exit the program.
 		case s := <-sigs:
 			switch s {
 			case syscall.SIGHUP:
-				klog.Info("Received SIGHUP, restarting.")
-				goto restart
+				// The config-manager sends a SIGHUP after updating the
+				// config file, which the config file watch also detects.
+				// The reload is skipped if the contents of the config
+				// file are unchanged since they were last checked so that
+				// the plugins are not reloaded twice for one change.
+				if configFile != nil {
+					changed, err := configFile.Changed()
+					if err != nil {
+						klog.Warningf("Failed to check config file: %v", err)
+					} else if !changed {
+						klog.Info("Received SIGHUP, but the config file is unchanged; skipping reload.")
+						continue
+					}
+				}
+				klog.Info("Received SIGHUP, reloading.")
+				restartTimeout = reload()
 			default:
 				klog.Infof("Received signal \"%v\", shutting down.", s)
 				goto exit
@@ -321,26 +584,118 @@ restart:
 	}
 exit:
 	err = stopPlugins(plugins)
+	stopRun()
+	stopReload()
 	if err != nil {
 		return fmt.Errorf("error stopping plugins: %v", err)
 	}
 	return nil
 }
 
-func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error) {
+func startPlugins(ctx context.Context, c *cli.Context, o *options) ([]plugin.Interface, *deviceInventory, bool, error) {
+	plugins, inventory, err := loadPlugins(ctx, c, o)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// Loop through all plugins, starting them if they have any devices
+	// to serve. If even one plugin fails to start properly, try
+	// starting them all again.
+	started := 0
+	for _, p := range plugins {
+		// Just continue if there are no devices to serve for plugin p.
+		if len(p.Devices()) == 0 {
+			continue
+		}
+
+		// Start the gRPC server for plugin p and connect it with the kubelet.
+		if err := p.Start(o.kubeletSocket); err != nil {
+			klog.Errorf("Failed to start plugin: %v", err)
+			return plugins, nil, true, nil
+		}
+		started++
+	}
+
+	if started == 0 {
+		klog.Info("No devices found. Waiting indefinitely.")
+	}
+
+	return plugins, inventory, false, nil
+}
+
+// reloadPlugins loads the plugins for the current configuration and replaces
+// the running plugins whose fingerprint has changed. Running plugins that are
+// unchanged are left untouched so that their devices remain available to the
+// kubelet. If the configuration cannot be loaded, the running plugins are
+// kept.
+func reloadPlugins(ctx context.Context, c *cli.Context, o *options, running []plugin.Interface) ([]plugin.Interface, *deviceInventory, bool, error) {
+	plugins, inventory, err := loadPlugins(ctx, c, o)
+	if err != nil {
+		return running, nil, false, err
+	}
+
+	byFingerprint := make(map[string]plugin.Interface)
+	for _, p := range running {
+		if fingerprint := p.Fingerprint(); fingerprint != "" {
+			byFingerprint[fingerprint] = p
+		}
+	}
+
+	kept := make(map[plugin.Interface]bool)
+	var changed []plugin.Interface
+	for i, p := range plugins {
+		if r, ok := byFingerprint[p.Fingerprint()]; ok && !kept[r] {
+			plugins[i] = r
+			kept[r] = true
+			continue
+		}
+		changed = append(changed, p)
+	}
+
+	// The running plugins that are not kept are stopped before the changed
+	// plugins are started since a plugin for the same resource uses the same
+	// socket.
+	var stale []plugin.Interface
+	for _, p := range running {
+		if !kept[p] {
+			stale = append(stale, p)
+		}
+	}
+	klog.Infof("Reloading plugins: %d unchanged, %d stopped, %d started.", len(kept), len(stale), len(changed))
+	if err := stopPlugins(stale); err != nil {
+		klog.Warningf("Failed to stop plugins: %v", err)
+	}
+
+	for _, p := range changed {
+		if len(p.Devices()) == 0 {
+			continue
+		}
+		if err := p.Start(o.kubeletSocket); err != nil {
+			klog.Errorf("Failed to start plugin: %v", err)
+			return plugins, inventory, true, nil
+		}
+	}
+
+	return plugins, inventory, false, nil
+}
+
+// loadPlugins loads the configuration and constructs the plugins for it
+// without starting them. An inventory of the GPUs on the node is also
+// returned if enabled.
+func loadPlugins(ctx context.Context, c *cli.Context, o *options) ([]plugin.Interface, *deviceInventory, error) {
 	// Load the configuration file
 	klog.Info("Loading configuration.")
 	config, err := loadConfig(c, o.flags)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to load config: %v", err)
+		return nil, nil, fmt.Errorf("unable to load config: %v", err)
 	}
 	spec.DisableResourceNamingInConfig(config)
 
-	driverRoot := root(*config.Flags.Plugin.ContainerDriverRoot)
+	driverRoot := driverroot.Root(*config.Flags.Plugin.ContainerDriverRoot)
 	// We construct an NVML library specifying the path to libnvidia-ml.so.1
 	// explicitly so that we don't have to rely on the library path.
 	nvmllib := nvml.New(
-		nvml.WithLibraryPath(driverRoot.tryResolveLibrary("libnvidia-ml.so.1")),
+		nvml.WithLibraryPath(driverRoot.TryResolveLibrary("libnvidia-ml.so.1")),
 	)
 	devicelib := device.New(nvmllib)
 	infolib := nvinfo.New(
@@ -351,53 +706,41 @@ func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error)
 
 	err = validateFlags(infolib, config)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to validate flags: %v", err)
+		return nil, nil, fmt.Errorf("unable to validate flags: %v", err)
 	}
 
 	// Update the configuration file with default resources.
 	klog.Info("Updating config with default resource matching patterns.")
 	err = rm.AddDefaultResourcesToConfig(infolib, nvmllib, devicelib, config)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to add default resources to config: %v", err)
+		return nil, nil, fmt.Errorf("unable to add default resources to config: %v", err)
 	}
 
 	// Print the config to the output.
 	configJSON, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to marshal config to JSON: %v", err)
+		return nil, nil, fmt.Errorf("failed to marshal config to JSON: %v", err)
 	}
 	klog.Infof("\nRunning with config:\n%v", string(configJSON))
 
-	// Get the set of plugins.
-	klog.Info("Retrieving plugins.")
-	plugins, err := GetPlugins(c.Context, infolib, nvmllib, devicelib, config, o)
-	if err != nil {
-		return nil, false, fmt.Errorf("error getting plugins: %v", err)
-	}
-
-	// Loop through all plugins, starting them if they have any devices
-	// to serve. If even one plugin fails to start properly, try
-	// starting them all again.
-	started := 0
-	for _, p := range plugins {
-		// Just continue if there are no devices to serve for plugin p.
-		if len(p.Devices()) == 0 {
-			continue
-		}
-
-		// Start the gRPC server for plugin p and connect it with the kubelet.
-		if err := p.Start(o.kubeletSocket); err != nil {
-			klog.Errorf("Failed to start plugin: %v", err)
-			return plugins, true, nil
+	// Take an inventory of the GPUs on the node so that GPUs that are
+	// added or removed can be detected.
+	var inventory *deviceInventory
+	if o.inventoryInterval > 0 {
+		inventory, err = newDeviceInventory(nvmllib)
+		if err != nil {
+			klog.Warningf("Unable to take device inventory; added or removed GPUs will not be detected: %v", err)
 		}
-		started++
 	}
 
-	if started == 0 {
-		klog.Info("No devices found. Waiting indefinitely.")
+	// Get the set of plugins.
+	klog.Info("Retrieving plugins.")
+	plugins, err := GetPlugins(ctx, infolib, nvmllib, devicelib, config, o)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting plugins: %v", err)
 	}
 
-	return plugins, false, nil
+	return plugins, inventory, nil
 }
 
 func stopPlugins(plugins []plugin.Interface) error {
@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
@@ -26,21 +27,23 @@ import (
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 	"github.com/NVIDIA/k8s-device-plugin/internal/imex"
 	"github.com/NVIDIA/k8s-device-plugin/internal/plugin"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
 // GetPlugins returns a set of plugins for the specified configuration.
 func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config, o *options) ([]plugin.Interface, error) {
 	// TODO: We could consider passing this as an argument since it should already be used to construct nvmllib.
-	driverRoot := root(*config.Flags.Plugin.ContainerDriverRoot)
+	driverRoot := driverroot.Root(*config.Flags.Plugin.ContainerDriverRoot)
 
 	deviceListStrategies, err := spec.NewDeviceListStrategies(*config.Flags.Plugin.DeviceListStrategy)
 	if err != nil {
 		return nil, fmt.Errorf("invalid device list strategy: %v", err)
 	}
 
-	imexChannels, err := imex.GetChannels(config, driverRoot.getDevRoot())
+	imexChannels, err := imex.GetChannels(config, driverRoot.GetDevRoot())
 	if err != nil {
 		return nil, fmt.Errorf("error querying IMEX channels: %w", err)
 	}
@@ -48,7 +51,7 @@ func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interf
 	cdiHandler, err := cdi.New(infolib, nvmllib, devicelib,
 		cdi.WithDeviceListStrategies(deviceListStrategies),
 		cdi.WithDriverRoot(string(driverRoot)),
-		cdi.WithDevRoot(driverRoot.getDevRoot()),
+		cdi.WithDevRoot(driverRoot.GetDevRoot()),
 		cdi.WithTargetDriverRoot(*config.Flags.NvidiaDriverRoot),
 		cdi.WithTargetDevRoot(*config.Flags.NvidiaDevRoot),
 		cdi.WithNvidiaCTKPath(*config.Flags.Plugin.NvidiaCTKPath),
@@ -64,13 +67,20 @@ func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interf
 		return nil, fmt.Errorf("unable to create cdi handler: %v", err)
 	}
 
-	plugins, err := plugin.New(ctx, infolib, nvmllib, devicelib,
+	pluginOptions := []plugin.Option{
 		plugin.WithCDIHandler(cdiHandler),
 		plugin.WithConfig(config),
 		plugin.WithDeviceListStrategies(deviceListStrategies),
 		plugin.WithFailOnInitError(*config.Flags.FailOnInitError),
 		plugin.WithImexChannels(imexChannels),
-	)
+	}
+	// The kubelet checkpoints its device allocations alongside its socket.
+	if o.kubeletSocket != "" {
+		checkpoint := filepath.Join(filepath.Dir(o.kubeletSocket), rm.KubeletCheckpointFile)
+		pluginOptions = append(pluginOptions, plugin.WithKubeletCheckpoint(checkpoint))
+	}
+
+	plugins, err := plugin.New(ctx, infolib, nvmllib, devicelib, pluginOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create plugins: %w", err)
 	}
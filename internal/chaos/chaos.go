@@ -0,0 +1,33 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+// Package chaos provides fault injection for resilience testing.
+// Faults are only injected in binaries built with the 'chaos' build tag. In
+// all other builds the functions in this package are no-ops.
+package chaos
+
+import "errors"
+
+// ErrInjected is returned for operations that were failed on purpose.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Stopper stops a process that is the target of fault injection.
+type Stopper struct {
+	// Quit asks the process to shut down gracefully.
+	Quit func() error
+	// Kill kills the process without giving it a chance to clean up.
+	Kill func() error
+}
@@ -0,0 +1,32 @@
+//go:build !chaos
+
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package chaos
+
+// Enabled indicates whether fault injection is compiled in.
+const Enabled = false
+
+// Inject is a no-op if chaos mode is not compiled in.
+func Inject(operation string) error {
+	return nil
+}
+
+// StartStopper is a no-op if chaos mode is not compiled in.
+func StartStopper(name string, stoppers ...Stopper) func() {
+	return func() {}
+}
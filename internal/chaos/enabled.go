@@ -0,0 +1,177 @@
+//go:build chaos
+
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package chaos
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Enabled indicates whether fault injection is compiled in.
+const Enabled = true
+
+const (
+	// envFailureRate defines the fraction [0, 1] of operations that fail.
+	envFailureRate = "CHAOS_FAILURE_RATE"
+	// envMaxDelay defines the maximum random delay added to each operation.
+	envMaxDelay = "CHAOS_MAX_DELAY"
+	// envStopRate defines the probability [0, 1] of stopping a process at
+	// each stop interval.
+	envStopRate = "CHAOS_STOP_RATE"
+	// envStopInterval defines how often a stop is attempted.
+	envStopInterval = "CHAOS_STOP_INTERVAL"
+	// envStopMode defines how a process is stopped. See stopModeKill and
+	// stopModeQuit.
+	envStopMode = "CHAOS_STOP_MODE"
+)
+
+const (
+	// stopModeKill kills a process to simulate a crash.
+	stopModeKill = "kill"
+	// stopModeQuit asks a process to shut down gracefully.
+	stopModeQuit = "quit"
+)
+
+type config struct {
+	failureRate  float64
+	maxDelay     time.Duration
+	stopRate     float64
+	stopInterval time.Duration
+	stopMode     string
+}
+
+// newConfigFromEnv reads the chaos config from the environment.
+func newConfigFromEnv(getenv func(string) string) (*config, error) {
+	c := &config{
+		stopInterval: time.Minute,
+		stopMode:     stopModeKill,
+	}
+
+	var err error
+	if c.failureRate, err = parseRate(getenv(envFailureRate)); err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", envFailureRate, err)
+	}
+	if c.stopRate, err = parseRate(getenv(envStopRate)); err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", envStopRate, err)
+	}
+	if v := getenv(envMaxDelay); v != "" {
+		if c.maxDelay, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid %v: %w", envMaxDelay, err)
+		}
+	}
+	if v := getenv(envStopInterval); v != "" {
+		if c.stopInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid %v: %w", envStopInterval, err)
+		}
+		if c.stopInterval <= 0 {
+			return nil, fmt.Errorf("invalid %v: must be positive", envStopInterval)
+		}
+	}
+	switch v := getenv(envStopMode); v {
+	case "":
+	case stopModeKill, stopModeQuit:
+		c.stopMode = v
+	default:
+		return nil, fmt.Errorf("invalid %v: %q is not one of [%v | %v]", envStopMode, v, stopModeKill, stopModeQuit)
+	}
+	return c, nil
+}
+
+func parseRate(v string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%v is not in the range [0, 1]", rate)
+	}
+	return rate, nil
+}
+
+var loadConfig = sync.OnceValue(func() *config {
+	c, err := newConfigFromEnv(os.Getenv)
+	if err != nil {
+		klog.Errorf("Disabling chaos mode: %v", err)
+		return &config{}
+	}
+	klog.Warningf("Chaos mode enabled: %+v", *c)
+	return c
+})
+
+// Inject randomly delays and fails the specified operation.
+func Inject(operation string) error {
+	c := loadConfig()
+	if c.maxDelay > 0 {
+		delay := rand.N(c.maxDelay)
+		klog.Infof("chaos: delaying %v by %v", operation, delay)
+		time.Sleep(delay)
+	}
+	if rand.Float64() < c.failureRate {
+		klog.Warningf("chaos: failing %v", operation)
+		return fmt.Errorf("%w: %v", ErrInjected, operation)
+	}
+	return nil
+}
+
+// StartStopper starts a goroutine that, at each stop interval, randomly stops
+// one of the specified processes. Depending on the configured stop mode, the
+// process is either killed or asked to quit. The returned function stops the
+// goroutine.
+func StartStopper(name string, stoppers ...Stopper) func() {
+	c := loadConfig()
+	stop := make(chan struct{})
+	if c.stopRate == 0 || len(stoppers) == 0 {
+		return func() {}
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.stopInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if rand.Float64() >= c.stopRate {
+				continue
+			}
+			i := rand.IntN(len(stoppers))
+			stopFunc := stoppers[i].Kill
+			if c.stopMode == stopModeQuit {
+				stopFunc = stoppers[i].Quit
+			}
+			klog.Warningf("chaos: stopping %v %d (mode=%v)", name, i, c.stopMode)
+			if err := stopFunc(); err != nil {
+				klog.Warningf("chaos: failed to stop %v %d: %v", name, i, err)
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() { close(stop) })
+}
@@ -0,0 +1,89 @@
+//go:build chaos
+
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigFromEnv(t *testing.T) {
+	testCases := []struct {
+		description    string
+		env            map[string]string
+		expectedConfig *config
+		expectedError  bool
+	}{
+		{
+			description:    "empty environment",
+			expectedConfig: &config{stopInterval: time.Minute, stopMode: stopModeKill},
+		},
+		{
+			description: "all values set",
+			env: map[string]string{
+				envFailureRate:  "0.1",
+				envMaxDelay:     "2s",
+				envStopRate:     "0.5",
+				envStopInterval: "10m",
+				envStopMode:     "quit",
+			},
+			expectedConfig: &config{
+				failureRate:  0.1,
+				maxDelay:     2 * time.Second,
+				stopRate:     0.5,
+				stopInterval: 10 * time.Minute,
+				stopMode:     stopModeQuit,
+			},
+		},
+		{
+			description:   "rate out of range",
+			env:           map[string]string{envFailureRate: "1.5"},
+			expectedError: true,
+		},
+		{
+			description:   "invalid delay",
+			env:           map[string]string{envMaxDelay: "soon"},
+			expectedError: true,
+		},
+		{
+			description:   "non-positive stop interval",
+			env:           map[string]string{envStopInterval: "0s"},
+			expectedError: true,
+		},
+		{
+			description:   "invalid stop mode",
+			env:           map[string]string{envStopMode: "pause"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			c, err := newConfigFromEnv(func(k string) string { return tc.env[k] })
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedConfig, c)
+		})
+	}
+}
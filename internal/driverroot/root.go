@@ -14,7 +14,9 @@
 # limitations under the License.
 **/
 
-package main
+// Package driverroot provides helpers for locating driver files under the
+// path at which an NVIDIA driver root is mounted in a container.
+package driverroot
 
 import (
 	"fmt"
@@ -22,15 +24,16 @@ import (
 	"path/filepath"
 )
 
-type root string
+// Root represents the path at which an NVIDIA driver root is mounted.
+type Root string
 
-func (r root) join(parts ...string) string {
+func (r Root) join(parts ...string) string {
 	return filepath.Join(append([]string{string(r)}, parts...)...)
 }
 
-// getDevRoot returns the dev root associated with the root.
+// GetDevRoot returns the dev root associated with the root.
 // If the root is not a dev root, this defaults to "/".
-func (r root) getDevRoot() string {
+func (r Root) GetDevRoot() string {
 	if r.isDevRoot() {
 		return string(r)
 	}
@@ -39,7 +42,7 @@ func (r root) getDevRoot() string {
 
 // isDevRoot checks whether the specified root is a dev root.
 // A dev root is defined as a root containing a /dev folder.
-func (r root) isDevRoot() bool {
+func (r Root) isDevRoot() bool {
 	stat, err := os.Stat(filepath.Join(string(r), "dev"))
 	if err != nil {
 		return false
@@ -47,7 +50,10 @@ func (r root) isDevRoot() bool {
 	return stat.IsDir()
 }
 
-func (r root) tryResolveLibrary(libraryName string) string {
+// TryResolveLibrary returns the path of the specified library under the root.
+// If the library cannot be found, the library name is returned unmodified so
+// that it is resolved using the library path instead.
+func (r Root) TryResolveLibrary(libraryName string) string {
 	if r == "" || r == "/" {
 		return libraryName
 	}
@@ -73,6 +79,33 @@ func (r root) tryResolveLibrary(libraryName string) string {
 	return libraryName
 }
 
+// TryResolveBinary returns the path of the specified executable under the
+// root. If the executable cannot be found, the name is returned unmodified so
+// that it is resolved using the PATH instead.
+func (r Root) TryResolveBinary(binaryName string) string {
+	if r == "" || r == "/" {
+		return binaryName
+	}
+
+	binarySearchPaths := []string{
+		"/usr/bin",
+		"/usr/sbin",
+		"/bin",
+		"/sbin",
+	}
+
+	for _, d := range binarySearchPaths {
+		b := r.join(d, binaryName)
+		info, err := os.Stat(b)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		return b
+	}
+
+	return binaryName
+}
+
 // resolveLink finds the target of a symlink or the file itself in the
 // case of a regular file.
 // This is equivalent to running `readlink -f ${l}`.
@@ -0,0 +1,70 @@
+/**
+# Copyright 2024 NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package driverroot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryResolveBinary(t *testing.T) {
+	driverRoot := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(driverRoot, "usr/bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(driverRoot, "usr/bin/nvidia-smi"), nil, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(driverRoot, "usr/bin/not-executable"), nil, 0644))
+
+	testCases := []struct {
+		description string
+		root        Root
+		binary      string
+		expected    string
+	}{
+		{
+			description: "empty root uses binary name",
+			root:        "",
+			binary:      "nvidia-smi",
+			expected:    "nvidia-smi",
+		},
+		{
+			description: "binary is resolved under root",
+			root:        Root(driverRoot),
+			binary:      "nvidia-smi",
+			expected:    filepath.Join(driverRoot, "usr/bin/nvidia-smi"),
+		},
+		{
+			description: "missing binary uses binary name",
+			root:        Root(driverRoot),
+			binary:      "nvidia-cuda-mps-control",
+			expected:    "nvidia-cuda-mps-control",
+		},
+		{
+			description: "non-executable file is ignored",
+			root:        Root(driverRoot),
+			binary:      "not-executable",
+			expected:    "not-executable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.root.TryResolveBinary(tc.binary))
+		})
+	}
+}
@@ -134,6 +134,10 @@ func newGPULabelers(manager resource.Manager, config *spec.Config) (Labeler, err
 		counts[name]++
 	}
 
+	// Since the replicas label applies to all full GPUs on the node, the
+	// smallest GPU is used to determine the replica count for resources that
+	// are replicated based on device memory.
+	var minMemoryMiB uint64
 	fullGPUs := make(map[string]resource.Device)
 	for _, device := range devicesByMigEnabled[false] {
 		name, err := device.GetName()
@@ -142,6 +146,15 @@ func newGPULabelers(manager resource.Manager, config *spec.Config) (Labeler, err
 		}
 		fullGPUs[name] = device
 		counts[name]++
+
+		memoryMiB, err := device.GetTotalMemoryMiB()
+		if err != nil {
+			klog.Warningf("Ignoring error getting memory info for device: %v", err)
+			continue
+		}
+		if minMemoryMiB == 0 || memoryMiB < minMemoryMiB {
+			minMemoryMiB = memoryMiB
+		}
 	}
 
 	if len(counts) > 1 {
@@ -168,7 +181,7 @@ func newGPULabelers(manager resource.Manager, config *spec.Config) (Labeler, err
 	// We construct labelers for the full GPUs.
 	// These override any resources with the same name that have MIG enabled.
 	for name, fullGPU := range fullGPUs {
-		l, err := NewGPUResourceLabeler(config, fullGPU, counts[name])
+		l, err := newGPUResourceLabeler(config, fullGPU, counts[name], minMemoryMiB)
 		if err != nil {
 			return nil, fmt.Errorf("failed to construct labeler: %v", err)
 		}
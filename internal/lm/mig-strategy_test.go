@@ -102,6 +102,31 @@ func TestMigStrategyNoneLabels(t *testing.T) {
 				"nvidia.com/gpu.product":          "MOCKMODEL-SHARED",
 			},
 		},
+		{
+			description: "replicas per GiB uses the smallest device",
+			devices: []resource.Device{
+				rt.NewDeviceMock(false).WithTotalMemoryMiB(40960),
+				rt.NewDeviceMock(false).WithTotalMemoryMiB(81920),
+			},
+			timeSlicing: spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{
+						Name:           "nvidia.com/gpu",
+						ReplicasPerGiB: 0.25,
+					},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/gpu.compute.major":    "8",
+				"nvidia.com/gpu.compute.minor":    "0",
+				"nvidia.com/gpu.family":           "ampere",
+				"nvidia.com/gpu.count":            "2",
+				"nvidia.com/gpu.replicas":         "10",
+				"nvidia.com/gpu.sharing-strategy": "time-slicing",
+				"nvidia.com/gpu.memory":           "81920",
+				"nvidia.com/gpu.product":          "MOCKMODEL-SHARED",
+			},
+		},
 		{
 			description: "sharing is not applied to single MIG device; replicas is zero",
 			devices: []resource.Device{
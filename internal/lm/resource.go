@@ -37,6 +37,15 @@ func NewGPUResourceLabelerWithoutSharing(device resource.Device, count int) (Lab
 
 // NewGPUResourceLabeler creates a resource labeler for the specified full GPU device with the specified count
 func NewGPUResourceLabeler(config *spec.Config, device resource.Device, count int) (Labeler, error) {
+	return newGPUResourceLabeler(config, device, count, 0)
+}
+
+// newGPUResourceLabeler creates a resource labeler for the specified full GPU
+// device with the specified count. If the resource is replicated based on
+// device memory, the replica count is determined from the specified memory so
+// that the label does not depend on which of the GPUs on a node is labelled.
+// If this is 0, the memory of the specified device is used instead.
+func newGPUResourceLabeler(config *spec.Config, device resource.Device, count int, replicaMemoryMiB uint64) (Labeler, error) {
 	if count == 0 {
 		return empty{}, nil
 	}
@@ -52,6 +61,10 @@ func NewGPUResourceLabeler(config *spec.Config, device resource.Device, count in
 	}
 
 	resourceLabeler := newResourceLabeler(fullGPUResourceName, config)
+	resourceLabeler.totalMemoryMiB = totalMemoryMiB
+	if replicaMemoryMiB != 0 {
+		resourceLabeler.totalMemoryMiB = replicaMemoryMiB
+	}
 
 	architectureLabels, err := newArchitectureLabels(resourceLabeler, device)
 	if err != nil {
@@ -92,7 +105,13 @@ func NewMIGResourceLabeler(resourceName spec.ResourceName, config *spec.Config,
 		return nil, fmt.Errorf("failed to get MIG profile name: %v", err)
 	}
 
+	totalMemoryMiB, err := device.GetTotalMemoryMiB()
+	if err != nil {
+		klog.Warningf("Ignoring error getting memory info for MIG device: %v", err)
+	}
+
 	resourceLabeler := newResourceLabeler(resourceName, config)
+	resourceLabeler.totalMemoryMiB = totalMemoryMiB
 
 	attributeLabels, err := newMigAttributeLabels(resourceLabeler, device)
 	if err != nil {
@@ -122,6 +141,9 @@ func newResourceLabeler(resourceName spec.ResourceName, config *spec.Config) res
 type resourceLabeler struct {
 	resourceName spec.ResourceName
 	sharing      *spec.Sharing
+	// totalMemoryMiB is used to determine the replica count for resources
+	// that are replicated based on device memory.
+	totalMemoryMiB uint64
 }
 
 // single creates a single label for the resource. The label key is
@@ -208,8 +230,8 @@ func (rl resourceLabeler) getProductName(parts ...string) string {
 func (rl resourceLabeler) getReplicas() int {
 	if rl.sharingDisabled() {
 		return 0
-	} else if r := rl.replicationInfo(); r != nil && r.Replicas > 0 {
-		return r.Replicas
+	} else if r := rl.replicationInfo(); r != nil && r.ReplicasFor(rl.totalMemoryMiB<<20) > 0 {
+		return r.ReplicasFor(rl.totalMemoryMiB << 20)
 	}
 	return 1
 }
@@ -222,7 +244,7 @@ func (rl resourceLabeler) sharingDisabled() bool {
 
 // isShared checks whether the resource is shared.
 func (rl resourceLabeler) isShared() bool {
-	if r := rl.replicationInfo(); r != nil && r.Replicas > 1 {
+	if r := rl.replicationInfo(); r != nil && (r.Replicas > 1 || r.ReplicasPerGiB > 0) {
 		return true
 	}
 	return false
@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/resource"
 	rt "github.com/NVIDIA/k8s-device-plugin/internal/resource/testing"
 )
 
@@ -270,6 +271,7 @@ func TestMigResourceLabeler(t *testing.T) {
 	testCases := []struct {
 		description    string
 		resourceName   spec.ResourceName
+		device         *resource.DeviceMock
 		count          int
 		timeSlicing    spec.ReplicatedResources
 		expectedLabels Labels
@@ -387,6 +389,43 @@ func TestMigResourceLabeler(t *testing.T) {
 				"nvidia.com/mig-1g.1gb.engines.ofa":      "0",
 			},
 		},
+		{
+			description:  "replicas per GiB uses the MIG device memory",
+			resourceName: "nvidia.com/mig-2g.20gb",
+			device: func() *resource.DeviceMock {
+				parent := rt.NewDeviceMock(true)
+				mig := rt.NewMigDevice(2, 2, 20480)
+				mig.GetDeviceHandleFromMigDeviceHandleFunc = func() (resource.Device, error) {
+					return parent, nil
+				}
+				return mig
+			}(),
+			count: 1,
+			timeSlicing: spec.ReplicatedResources{
+				Resources: []spec.ReplicatedResource{
+					{
+						Name:           "nvidia.com/mig-2g.20gb",
+						Rename:         "nvidia.com/mig-2g.20gb.shared",
+						ReplicasPerGiB: 0.25,
+					},
+				},
+			},
+			expectedLabels: Labels{
+				"nvidia.com/mig-2g.20gb.count":            "1",
+				"nvidia.com/mig-2g.20gb.replicas":         "5",
+				"nvidia.com/mig-2g.20gb.sharing-strategy": "time-slicing",
+				"nvidia.com/mig-2g.20gb.memory":           "20480",
+				"nvidia.com/mig-2g.20gb.product":          "MOCKMODEL-MIG-2g.20480gb",
+				"nvidia.com/mig-2g.20gb.multiprocessors":  "0",
+				"nvidia.com/mig-2g.20gb.slices.gi":        "2",
+				"nvidia.com/mig-2g.20gb.slices.ci":        "2",
+				"nvidia.com/mig-2g.20gb.engines.copy":     "0",
+				"nvidia.com/mig-2g.20gb.engines.decoder":  "0",
+				"nvidia.com/mig-2g.20gb.engines.encoder":  "0",
+				"nvidia.com/mig-2g.20gb.engines.jpeg":     "0",
+				"nvidia.com/mig-2g.20gb.engines.ofa":      "0",
+			},
+		},
 		{
 			description:  "mig mixed rename does not append",
 			resourceName: "nvidia.com/mig-1g.1gb",
@@ -425,7 +464,11 @@ func TestMigResourceLabeler(t *testing.T) {
 					TimeSlicing: tc.timeSlicing,
 				},
 			}
-			l, err := NewMIGResourceLabeler(tc.resourceName, config, device, tc.count)
+			d := device
+			if tc.device != nil {
+				d = tc.device
+			}
+			l, err := NewMIGResourceLabeler(tc.resourceName, config, d, tc.count)
 			require.NoError(t, err)
 
 			labels, err := l.Labels()
@@ -23,4 +23,8 @@ type Interface interface {
 	Devices() rm.Devices
 	Start(string) error
 	Stop() error
+	// Fingerprint identifies the resource, devices, and configuration that
+	// are served by the plugin. A running plugin need not be restarted for a
+	// new plugin with the same fingerprint.
+	Fingerprint() string
 }
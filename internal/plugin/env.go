@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// protectedEnvVars are always set by the plugin, regardless of the configured
+// envvars to preserve, since they control which devices and MPS daemon are
+// accessible in a container.
+var protectedEnvVars = []string{
+	deviceListEnvVar,
+	spec.ImexChannelEnvVar,
+	"CUDA_MPS_PIPE_DIRECTORY",
+}
+
+// applyEnvPolicy removes the envvars that are configured to be preserved from
+// the response. Envvars in an allocate response override those set in the
+// container spec, so removing them allows the values from the container spec
+// to be used instead. The envvars that override the container spec, those that
+// are preserved, and those that are set because they are protected are logged
+// so that it is clear which values a container sees.
+func (plugin *nvidiaDevicePlugin) applyEnvPolicy(response *pluginapi.ContainerAllocateResponse) {
+	if plugin.config.Flags.Plugin.PreserveEnv == nil {
+		return
+	}
+	patterns := *plugin.config.Flags.Plugin.PreserveEnv
+
+	var overridden, preserved, protected []string
+	for _, name := range slices.Sorted(maps.Keys(response.Envs)) {
+		if !matchesEnvPattern(patterns, name) {
+			overridden = append(overridden, name)
+			continue
+		}
+		if slices.Contains(protectedEnvVars, name) {
+			protected = append(protected, name)
+			continue
+		}
+		preserved = append(preserved, name)
+		delete(response.Envs, name)
+	}
+
+	klog.InfoS("Applied envvar policy to allocate response", "resource", plugin.rm.Resource(), "overridden", overridden, "preserved", preserved, "protected", protected)
+}
+
+// matchesEnvPattern checks whether the specified envvar matches any of the
+// patterns. A pattern ending in '*' matches all envvars with the preceding
+// prefix; other patterns must match exactly.
+func matchesEnvPattern(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
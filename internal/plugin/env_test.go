@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	v1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestApplyEnvPolicy(t *testing.T) {
+	envs := map[string]string{
+		"NVIDIA_VISIBLE_DEVICES":            "GPU-0",
+		"NVIDIA_GDS":                        "enabled",
+		"CUDA_MPS_PIPE_DIRECTORY":           "/mps/nvidia.com/gpu/pipe",
+		"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "50",
+		"NCCL_P2P_LEVEL":                    "PIX",
+	}
+
+	testCases := []struct {
+		description  string
+		preserveEnv  *[]string
+		expectedEnvs map[string]string
+	}{
+		{
+			description:  "no policy",
+			expectedEnvs: envs,
+		},
+		{
+			description: "exact match",
+			preserveEnv: &[]string{"NCCL_P2P_LEVEL"},
+			expectedEnvs: map[string]string{
+				"NVIDIA_VISIBLE_DEVICES":            "GPU-0",
+				"NVIDIA_GDS":                        "enabled",
+				"CUDA_MPS_PIPE_DIRECTORY":           "/mps/nvidia.com/gpu/pipe",
+				"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "50",
+			},
+		},
+		{
+			description: "prefix match",
+			preserveEnv: &[]string{"CUDA_*"},
+			expectedEnvs: map[string]string{
+				"NVIDIA_VISIBLE_DEVICES":  "GPU-0",
+				"NVIDIA_GDS":              "enabled",
+				"CUDA_MPS_PIPE_DIRECTORY": "/mps/nvidia.com/gpu/pipe",
+				"NCCL_P2P_LEVEL":          "PIX",
+			},
+		},
+		{
+			description: "protected envvars are always set",
+			preserveEnv: &[]string{"*"},
+			expectedEnvs: map[string]string{
+				"NVIDIA_VISIBLE_DEVICES":  "GPU-0",
+				"CUDA_MPS_PIPE_DIRECTORY": "/mps/nvidia.com/gpu/pipe",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			plugin := nvidiaDevicePlugin{
+				rm: &rm.ResourceManagerMock{
+					ResourceFunc: func() v1.ResourceName {
+						return "nvidia.com/gpu"
+					},
+				},
+				config: &v1.Config{
+					Flags: v1.Flags{
+						CommandLineFlags: v1.CommandLineFlags{
+							Plugin: &v1.PluginCommandLineFlags{
+								PreserveEnv: tc.preserveEnv,
+							},
+						},
+					},
+				},
+			}
+
+			response := &pluginapi.ContainerAllocateResponse{
+				Envs: make(map[string]string),
+			}
+			for k, v := range envs {
+				response.Envs[k] = v
+			}
+
+			plugin.applyEnvPolicy(response)
+			require.EqualValues(t, tc.expectedEnvs, response.Envs)
+		})
+	}
+}
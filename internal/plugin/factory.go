@@ -18,7 +18,9 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
@@ -44,6 +46,8 @@ type options struct {
 	deviceListStrategies spec.DeviceListStrategies
 
 	imexChannels imex.Channels
+
+	kubeletCheckpoint string
 }
 
 // New a new set of plugins with the supplied options.
@@ -71,6 +75,10 @@ func New(ctx context.Context, infolib info.Interface, nvmllib nvml.Interface, de
 		return nil, fmt.Errorf("failed to construct resource managers: %w", err)
 	}
 
+	if o.kubeletCheckpoint != "" {
+		o.adoptCheckpointedIDs(resourceManagers)
+	}
+
 	var plugins []Interface
 	for _, resourceManager := range resourceManagers {
 		plugin, err := o.devicePluginForResource(ctx, resourceManager)
@@ -122,6 +130,23 @@ func (o *options) getResourceManagers() ([]rm.ResourceManager, error) {
 	}
 }
 
+// adoptCheckpointedIDs ensures that the device IDs that the kubelet has
+// checkpointed as allocated remain advertised by the resource managers, even
+// if the replicas generated for a GPU have changed since the last restart.
+func (o *options) adoptCheckpointedIDs(resourceManagers []rm.ResourceManager) {
+	ids, err := rm.ReadCheckpointedDeviceIDs(o.kubeletCheckpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		klog.Warningf("Unable to adopt checkpointed device IDs: %v", err)
+		return
+	}
+	for _, r := range resourceManagers {
+		r.AdoptCheckpointedIDs(ids[r.Resource()])
+	}
+}
+
 func (o *options) resolveStrategy(strategy string) string {
 	if strategy != "" && strategy != "auto" {
 		return strategy
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// Fingerprint returns a hash of the resource name, the device IDs, and the
+// parts of the config that apply to the resource. Since replicas are
+// reflected in the device IDs, the sharing config of other resources does not
+// affect the fingerprint.
+func (plugin *nvidiaDevicePlugin) Fingerprint() string {
+	resource := plugin.rm.Resource()
+	ids := plugin.rm.Devices().GetIDs()
+	slices.Sort(ids)
+
+	fingerprint := struct {
+		Resource        spec.ResourceName    `json:"resource"`
+		DeviceIDs       []string             `json:"deviceIDs"`
+		SharingStrategy spec.SharingStrategy `json:"sharingStrategy"`
+		Config          spec.Config          `json:"config"`
+	}{
+		Resource:        resource,
+		DeviceIDs:       ids,
+		SharingStrategy: plugin.config.Sharing.SharingStrategy(),
+		Config:          configForResource(plugin.config, resource),
+	}
+
+	data, err := json.Marshal(fingerprint)
+	if err != nil {
+		// A plugin without a fingerprint is always restarted.
+		klog.Warningf("Failed to compute fingerprint for '%s': %v", resource, err)
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// configForResource returns a copy of the config that only includes the
+// sharing config for the specified resource. The resource patterns are
+// omitted since they only determine which devices belong to the resource.
+func configForResource(config *spec.Config, resource spec.ResourceName) spec.Config {
+	c := *config
+	c.Resources = spec.Resources{}
+	c.Sharing.TimeSlicing = replicatedResourcesFor(&config.Sharing.TimeSlicing, resource)
+	if config.Sharing.MPS != nil {
+		mps := replicatedResourcesFor(config.Sharing.MPS, resource)
+		c.Sharing.MPS = &mps
+	}
+	return c
+}
+
+// replicatedResourcesFor returns a copy of the replicated resources that only
+// includes the entries that may apply to the specified resource.
+func replicatedResourcesFor(rrs *spec.ReplicatedResources, resource spec.ResourceName) spec.ReplicatedResources {
+	filtered := *rrs
+	filtered.Resources = nil
+	for _, r := range rrs.Resources {
+		if r.Name == resource || r.Rename == resource || r.Name.DefaultSharedRename() == resource {
+			filtered.Resources = append(filtered.Resources, r)
+		}
+	}
+	return filtered
+}
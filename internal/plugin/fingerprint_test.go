@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	v1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestFingerprint(t *testing.T) {
+	newConfig := func(replicas map[v1.ResourceName]int) *v1.Config {
+		config := &v1.Config{
+			Flags: v1.Flags{
+				CommandLineFlags: v1.CommandLineFlags{
+					Plugin: &v1.PluginCommandLineFlags{
+						DeviceIDStrategy: ptr(v1.DeviceIDStrategyUUID),
+					},
+				},
+			},
+		}
+		for name, r := range replicas {
+			config.Sharing.TimeSlicing.Resources = append(config.Sharing.TimeSlicing.Resources, v1.ReplicatedResource{
+				Name:     name,
+				Devices:  v1.ReplicatedDevices{All: true},
+				Replicas: r,
+			})
+		}
+		return config
+	}
+	newPlugin := func(resource v1.ResourceName, config *v1.Config, ids ...string) *nvidiaDevicePlugin {
+		devices := make(rm.Devices)
+		for _, id := range ids {
+			devices[id] = &rm.Device{Device: pluginapi.Device{ID: id}}
+		}
+		return &nvidiaDevicePlugin{
+			rm: &rm.ResourceManagerMock{
+				ResourceFunc: func() v1.ResourceName { return resource },
+				DevicesFunc:  func() rm.Devices { return devices },
+			},
+			config: config,
+		}
+	}
+
+	base := newPlugin("nvidia.com/gpu", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2}), "GPU-0::0", "GPU-0::1")
+
+	testCases := []struct {
+		description string
+		plugin      *nvidiaDevicePlugin
+		expectEqual bool
+	}{
+		{
+			description: "identical plugin",
+			plugin:      newPlugin("nvidia.com/gpu", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2}), "GPU-0::1", "GPU-0::0"),
+			expectEqual: true,
+		},
+		{
+			description: "sharing config of another resource changed",
+			plugin:      newPlugin("nvidia.com/gpu", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2, "nvidia.com/mig-1g.5gb": 4}), "GPU-0::0", "GPU-0::1"),
+			expectEqual: true,
+		},
+		{
+			description: "replicas of the resource changed",
+			plugin:      newPlugin("nvidia.com/gpu", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 3}), "GPU-0::0", "GPU-0::1", "GPU-0::2"),
+			expectEqual: false,
+		},
+		{
+			description: "devices changed",
+			plugin:      newPlugin("nvidia.com/gpu", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2}), "GPU-1::0", "GPU-1::1"),
+			expectEqual: false,
+		},
+		{
+			description: "resource renamed",
+			plugin:      newPlugin("nvidia.com/gpu.shared", newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2}), "GPU-0::0", "GPU-0::1"),
+			expectEqual: false,
+		},
+		{
+			description: "plugin flags changed",
+			plugin: func() *nvidiaDevicePlugin {
+				config := newConfig(map[v1.ResourceName]int{"nvidia.com/gpu": 2})
+				config.Flags.Plugin.DeviceIDStrategy = ptr(v1.DeviceIDStrategyIndex)
+				return newPlugin("nvidia.com/gpu", config, "GPU-0::0", "GPU-0::1")
+			}(),
+			expectEqual: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.NotEmpty(t, tc.plugin.Fingerprint())
+			if tc.expectEqual {
+				require.Equal(t, base.Fingerprint(), tc.plugin.Fingerprint())
+				return
+			}
+			require.NotEqual(t, base.Fingerprint(), tc.plugin.Fingerprint())
+		})
+	}
+}
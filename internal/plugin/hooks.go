@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/reaper"
+)
+
+// allocateHookTimeout defines the maximum time that an allocate hook may run
+// for before it is killed and the allocation fails.
+const allocateHookTimeout = 30 * time.Second
+
+type allocateHookStage string
+
+const (
+	preAllocateHookStage  = allocateHookStage("pre-allocate")
+	postAllocateHookStage = allocateHookStage("post-allocate")
+)
+
+// allocateHookContext is passed as JSON on the stdin of an allocate hook.
+type allocateHookContext struct {
+	Stage             allocateHookStage              `json:"stage"`
+	Resource          string                         `json:"resource"`
+	ContainerRequests []allocateHookContainerRequest `json:"containerRequests"`
+	Response          *pluginapi.AllocateResponse    `json:"response,omitempty"`
+}
+
+// allocateHookContainerRequest describes the devices requested for a single
+// container.
+type allocateHookContainerRequest struct {
+	DeviceIDs []string `json:"deviceIDs"`
+	// UUIDs are the UUIDs of the GPUs or MIG devices backing the requested
+	// device IDs.
+	UUIDs []string `json:"uuids"`
+}
+
+// runAllocateHook runs the specified hook for an allocate request. The
+// response is only included for the post-allocate stage.
+func (plugin *nvidiaDevicePlugin) runAllocateHook(ctx context.Context, stage allocateHookStage, hook *string, reqs *pluginapi.AllocateRequest, response *pluginapi.AllocateResponse) error {
+	if hook == nil || *hook == "" {
+		return nil
+	}
+
+	hookContext := allocateHookContext{
+		Stage:    stage,
+		Resource: string(plugin.rm.Resource()),
+		Response: response,
+	}
+	for _, req := range reqs.ContainerRequests {
+		hookContext.ContainerRequests = append(hookContext.ContainerRequests, allocateHookContainerRequest{
+			DeviceIDs: req.DevicesIds,
+			UUIDs:     plugin.rm.Devices().Subset(req.DevicesIds).GetUUIDs(),
+		})
+	}
+	input, err := json.Marshal(hookContext)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %v hook context: %w", stage, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, allocateHookTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, *hook)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	klog.V(4).Infof("Running %v hook %v for %v", stage, *hook, reqs.ContainerRequests)
+	if err := reaper.Run(cmd); err != nil {
+		klog.InfoS("Allocate hook failed", "stage", stage, "hook", *hook, "error", err, "stdout", strings.TrimSpace(stdout.String()), "stderr", strings.TrimSpace(stderr.String()))
+		return fmt.Errorf("%v hook %v failed: %w: %v", stage, *hook, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	v1 "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestRunAllocateHook(t *testing.T) {
+	testCases := []struct {
+		description     string
+		script          string
+		stage           allocateHookStage
+		response        *pluginapi.AllocateResponse
+		expectedError   bool
+		expectedContext *allocateHookContext
+	}{
+		{
+			description: "pre-allocate hook receives context",
+			script:      "#!/bin/sh\ncat > \"$(dirname \"$0\")/context.json\"\n",
+			stage:       preAllocateHookStage,
+			expectedContext: &allocateHookContext{
+				Stage:    preAllocateHookStage,
+				Resource: "nvidia.com/gpu",
+				ContainerRequests: []allocateHookContainerRequest{
+					{DeviceIDs: []string{"GPU-0::1"}, UUIDs: []string{"GPU-0"}},
+				},
+			},
+		},
+		{
+			description: "post-allocate hook receives response",
+			script:      "#!/bin/sh\ncat > \"$(dirname \"$0\")/context.json\"\n",
+			stage:       postAllocateHookStage,
+			response: &pluginapi.AllocateResponse{
+				ContainerResponses: []*pluginapi.ContainerAllocateResponse{
+					{Envs: map[string]string{"NVIDIA_VISIBLE_DEVICES": "GPU-0"}},
+				},
+			},
+			expectedContext: &allocateHookContext{
+				Stage:    postAllocateHookStage,
+				Resource: "nvidia.com/gpu",
+				ContainerRequests: []allocateHookContainerRequest{
+					{DeviceIDs: []string{"GPU-0::1"}, UUIDs: []string{"GPU-0"}},
+				},
+				Response: &pluginapi.AllocateResponse{
+					ContainerResponses: []*pluginapi.ContainerAllocateResponse{
+						{Envs: map[string]string{"NVIDIA_VISIBLE_DEVICES": "GPU-0"}},
+					},
+				},
+			},
+		},
+		{
+			description:   "failing hook returns error",
+			script:        "#!/bin/sh\necho rejected >&2\nexit 1\n",
+			stage:         preAllocateHookStage,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dir := t.TempDir()
+			hook := filepath.Join(dir, "hook")
+			require.NoError(t, os.WriteFile(hook, []byte(tc.script), 0700))
+
+			plugin := nvidiaDevicePlugin{
+				rm: &rm.ResourceManagerMock{
+					ResourceFunc: func() v1.ResourceName {
+						return "nvidia.com/gpu"
+					},
+					DevicesFunc: func() rm.Devices {
+						return rm.Devices{
+							"GPU-0::1": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::1"}},
+						}
+					},
+				},
+			}
+			request := &pluginapi.AllocateRequest{
+				ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+					{DevicesIds: []string{"GPU-0::1"}},
+				},
+			}
+
+			err := plugin.runAllocateHook(context.TODO(), tc.stage, &hook, request, tc.response)
+			if tc.expectedError {
+				require.ErrorContains(t, err, "rejected")
+				return
+			}
+			require.NoError(t, err)
+
+			contents, err := os.ReadFile(filepath.Join(dir, "context.json"))
+			require.NoError(t, err)
+			var hookContext allocateHookContext
+			require.NoError(t, json.Unmarshal(contents, &hookContext))
+			require.EqualValues(t, tc.expectedContext, &hookContext)
+		})
+	}
+}
+
+func TestAllocateIgnoresPostAllocateHookFailure(t *testing.T) {
+	hook := filepath.Join(t.TempDir(), "hook")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\necho rejected >&2\nexit 1\n"), 0700))
+
+	plugin := nvidiaDevicePlugin{
+		rm: &rm.ResourceManagerMock{},
+		config: &v1.Config{
+			Flags: v1.Flags{
+				CommandLineFlags: v1.CommandLineFlags{
+					Plugin: &v1.PluginCommandLineFlags{
+						DeviceIDStrategy: ptr(v1.DeviceIDStrategyUUID),
+						PostAllocateHook: &hook,
+					},
+				},
+			},
+		},
+		deviceListStrategies: v1.DeviceListStrategies{"envvar": true},
+	}
+	request := &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIds: []string{"GPU-0"}},
+		},
+	}
+
+	response, err := plugin.Allocate(context.TODO(), request)
+	require.NoError(t, err)
+	require.Len(t, response.ContainerResponses, 1)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// mpsProbeLatency records the round-trip time of the probes sent to the MPS
+// control daemon of each resource.
+var mpsProbeLatency = newLatencyHistogram(
+	"nvidia_device_plugin_mps_probe_latency_seconds",
+	"Round-trip time of the probes sent to the MPS control daemon through its control pipe.",
+	[]float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+)
+
+// latencyHistogram is a histogram of latencies per resource that is exposed
+// in the Prometheus text format.
+type latencyHistogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	sync.Mutex
+	series map[spec.ResourceName]*latencySeries
+}
+
+// latencySeries stores the observations of a single resource. The bucket
+// counts are cumulative.
+type latencySeries struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// newLatencyHistogram creates a histogram with the specified bucket upper
+// bounds in seconds.
+func newLatencyHistogram(name string, help string, buckets []float64) *latencyHistogram {
+	return &latencyHistogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		series:  make(map[spec.ResourceName]*latencySeries),
+	}
+}
+
+// observe records the specified latency for a resource.
+func (h *latencyHistogram) observe(resource spec.ResourceName, latency time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	s := h.series[resource]
+	if s == nil {
+		s = &latencySeries{buckets: make([]uint64, len(h.buckets))}
+		h.series[resource] = s
+	}
+	seconds := latency.Seconds()
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.sum += seconds
+}
+
+// write writes the histogram in the Prometheus text format.
+func (h *latencyHistogram) write(w io.Writer) error {
+	h.Lock()
+	defer h.Unlock()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", h.name)
+	for _, resource := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[resource]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(&b, "%s_bucket{resource=%q,le=%q} %d\n", h.name, resource, strconv.FormatFloat(bound, 'g', -1, 64), s.buckets[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{resource=%q,le=\"+Inf\"} %d\n", h.name, resource, s.count)
+		fmt.Fprintf(&b, "%s_sum{resource=%q} %s\n", h.name, resource, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{resource=%q} %d\n", h.name, resource, s.count)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// MetricsHandler returns an HTTP handler that exposes the metrics of the
+// device plugins in the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = mpsProbeLatency.write(w)
+	})
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram("probe_latency_seconds", "Probe latency.", []float64{0.1, 1})
+	h.observe("nvidia.com/gpu", 50*time.Millisecond)
+	h.observe("nvidia.com/gpu", 500*time.Millisecond)
+	h.observe("nvidia.com/gpu", 5*time.Second)
+
+	var b strings.Builder
+	require.NoError(t, h.write(&b))
+
+	expected := `# HELP probe_latency_seconds Probe latency.
+# TYPE probe_latency_seconds histogram
+probe_latency_seconds_bucket{resource="nvidia.com/gpu",le="0.1"} 1
+probe_latency_seconds_bucket{resource="nvidia.com/gpu",le="1"} 2
+probe_latency_seconds_bucket{resource="nvidia.com/gpu",le="+Inf"} 3
+probe_latency_seconds_sum{resource="nvidia.com/gpu"} 5.55
+probe_latency_seconds_count{resource="nvidia.com/gpu"} 3
+`
+	require.Equal(t, expected, b.String())
+}
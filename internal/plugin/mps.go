@@ -17,22 +17,48 @@
 package plugin
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/cmd/mps-control-daemon/mps"
+	"github.com/NVIDIA/k8s-device-plugin/internal/driverroot"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 )
 
+const (
+	// defaultMPSHealthCheckInterval defines how often the MPS control daemon
+	// is probed if no interval is configured.
+	defaultMPSHealthCheckInterval = 30 * time.Second
+	// defaultMPSDegradedLatency is the probe latency above which the MPS
+	// control daemon is considered degraded if no latency is configured.
+	// Clients connecting to an overloaded daemon are likely to time out well
+	// before the probe fails outright.
+	defaultMPSDegradedLatency = 2 * time.Second
+	// mpsClientConfigEnvvar is the envvar that points a container to its MPS
+	// client configuration.
+	mpsClientConfigEnvvar = "NVIDIA_MPS_CLIENT_CONFIG"
+)
+
 type mpsOptions struct {
-	enabled      bool
-	resourceName spec.ResourceName
-	daemon       *mps.Daemon
-	hostRoot     mps.Root
+	enabled             bool
+	resourceName        spec.ResourceName
+	daemon              *mps.Daemon
+	hostRoot            mps.Root
+	healthCheckInterval time.Duration
+	degradedLatency     time.Duration
 }
 
 // getMPSOptions returns the MPS options specified for the resource manager.
@@ -50,33 +76,58 @@ func (o *options) getMPSOptions(resourceManager rm.ResourceManager) (mpsOptions,
 	}
 
 	m := mpsOptions{
-		enabled:      true,
-		resourceName: resourceManager.Resource(),
-		daemon:       mps.NewDaemon(resourceManager, mps.ContainerRoot),
-		hostRoot:     mps.Root(*o.config.Flags.MpsRoot),
+		enabled:             true,
+		resourceName:        resourceManager.Resource(),
+		daemon:              mps.NewDaemon(resourceManager, mps.ContainerRoot, driverroot.Root(*o.config.Flags.Plugin.ContainerDriverRoot)),
+		hostRoot:            mps.Root(*o.config.Flags.MpsRoot),
+		healthCheckInterval: defaultMPSHealthCheckInterval,
+		degradedLatency:     defaultMPSDegradedLatency,
+	}
+	if flags := o.config.Flags.Plugin; flags != nil {
+		if flags.MpsHealthCheckInterval != nil {
+			m.healthCheckInterval = time.Duration(*flags.MpsHealthCheckInterval)
+		}
+		if flags.MpsDegradedLatency != nil {
+			m.degradedLatency = time.Duration(*flags.MpsDegradedLatency)
+		}
 	}
 	return m, nil
 }
 
-func (m *mpsOptions) waitForDaemon() error {
+func (m *mpsOptions) waitForDaemon(ctx context.Context) error {
 	if m == nil || !m.enabled {
 		return nil
 	}
 	// TODO: Check the .ready file here.
 	// TODO: Have some retry strategy here.
-	if err := m.daemon.AssertHealthy(); err != nil {
+	if err := m.daemon.AssertHealthy(ctx); err != nil {
 		return fmt.Errorf("error checking MPS daemon health: %w", err)
 	}
 	klog.InfoS("MPS daemon is healthy", "resource", m.resourceName)
 	return nil
 }
 
-func (m *mpsOptions) updateReponse(response *pluginapi.ContainerAllocateResponse) {
+// updateReponse adds the envvars and mounts required to use the MPS daemon to
+// the response. The client configuration is added separately by
+// updateClientConfig once the response is otherwise complete.
+func (m *mpsOptions) updateReponse(response *pluginapi.ContainerAllocateResponse, requestIds []string) {
 	if m == nil || !m.enabled {
 		return
 	}
 	// TODO: We should check that the deviceIDs are shared using MPS.
 	response.Envs["CUDA_MPS_PIPE_DIRECTORY"] = m.daemon.PipeDir()
+	// Limit the client to the fraction of each GPU's replicas that it was
+	// allocated, since the default set by the daemon cannot account for GPUs
+	// with different numbers of replicas.
+	if threadPercentage := m.daemon.ClientActiveThreadPercentage(requestIds); threadPercentage != "" {
+		response.Envs["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"] = threadPercentage
+	}
+	// Likewise, limit the pinned device memory of the client to the replicas
+	// that it was allocated so that one client cannot exhaust the memory of
+	// the others sharing a GPU.
+	if memoryLimit := m.daemon.ClientPinnedDeviceMemoryLimit(requestIds); memoryLimit != "" {
+		response.Envs["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"] = memoryLimit
+	}
 
 	response.Mounts = append(response.Mounts,
 		&pluginapi.Mount{
@@ -89,3 +140,198 @@ func (m *mpsOptions) updateReponse(response *pluginapi.ContainerAllocateResponse
 		},
 	)
 }
+
+// updateClientConfig writes the MPS client configuration for the requested
+// devices and adds it to the response. This must be called once the envvars
+// of the response are final, so that the limits in the configuration match
+// those that apply to the container.
+func (m *mpsOptions) updateClientConfig(ctx context.Context, response *pluginapi.ContainerAllocateResponse, requestIds []string) {
+	if m == nil || !m.enabled {
+		return
+	}
+	// The client configuration is only informational, so failing to write it
+	// does not fail the allocation.
+	if err := m.updateResponseForClientConfig(ctx, response, requestIds); err != nil {
+		klog.Warningf("Failed to write MPS client configuration for %v: %v", requestIds, err)
+	}
+}
+
+// mpsClientConfig describes the MPS server that a container is expected to
+// connect to and the limits that apply to it. This allows applications in the
+// container to detect that they are not running under the intended server.
+type mpsClientConfig struct {
+	Resource                spec.ResourceName `json:"resource"`
+	Devices                 []string          `json:"devices"`
+	PipeDirectory           string            `json:"pipeDirectory"`
+	ServerPIDs              []int             `json:"serverPIDs"`
+	ActiveThreadPercentage  string            `json:"activeThreadPercentage,omitempty"`
+	PinnedDeviceMemoryLimit string            `json:"pinnedDeviceMemoryLimit,omitempty"`
+}
+
+// updateResponseForClientConfig writes the MPS client configuration for the
+// requested devices and mounts it into the container.
+func (m *mpsOptions) updateResponseForClientConfig(ctx context.Context, response *pluginapi.ContainerAllocateResponse, requestIds []string) error {
+	// The server is only started once the first client connects, so the list
+	// of server PIDs may be empty.
+	serverPIDs, err := m.daemon.ServerPIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting MPS server PIDs: %w", err)
+	}
+
+	devices := slices.Sorted(slices.Values(requestIds))
+	config := mpsClientConfig{
+		Resource:                m.resourceName,
+		Devices:                 devices,
+		PipeDirectory:           m.daemon.PipeDir(),
+		ServerPIDs:              serverPIDs,
+		ActiveThreadPercentage:  response.Envs["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"],
+		PinnedDeviceMemoryLimit: response.Envs["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"],
+	}
+
+	// A set of devices is only allocated to one container at a time, so the
+	// file for the devices can be reused once the container has exited. The
+	// files are not removed when a container exits since the kubelet mounts
+	// the same file again if the container is restarted. The MPS control
+	// daemon removes them when it is stopped or restarted, since the server
+	// PIDs that they contain are no longer valid then.
+	sum := sha256.Sum256([]byte(strings.Join(devices, ",")))
+	filename := hex.EncodeToString(sum[:]) + ".json"
+	if err := writeClientConfig(filepath.Join(mps.ContainerRoot.ClientConfigDir(m.resourceName), filename), &config); err != nil {
+		return err
+	}
+
+	clientConfigFile := mps.ContainerRoot.ClientConfigFile(m.resourceName)
+	response.Envs[mpsClientConfigEnvvar] = clientConfigFile
+	response.Mounts = append(response.Mounts,
+		&pluginapi.Mount{
+			ContainerPath: clientConfigFile,
+			HostPath:      filepath.Join(m.hostRoot.ClientConfigDir(m.resourceName), filename),
+			ReadOnly:      true,
+		},
+	)
+	return nil
+}
+
+// writeClientConfig atomically writes the client configuration to the
+// specified path so that a container never sees a partially written file.
+func writeClientConfig(path string, config *mpsClientConfig) error {
+	contents, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling client config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".client-*.json")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// checkHealth periodically probes the MPS control daemon until the stop
+// channel is closed. The devices shared using the daemon are marked as
+// unhealthy while it is degraded and as healthy once it recovers. A probe that
+// is in progress is cancelled when the stop channel is closed.
+func (m *mpsOptions) checkHealth(stop <-chan interface{}, health chan<- *rm.Device) {
+	if m == nil || !m.enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	monitor := &mpsHealthMonitor{
+		resourceName: m.resourceName,
+		probe: func() error {
+			return m.daemon.AssertHealthy(ctx)
+		},
+		devices:   m.daemon.Devices,
+		threshold: m.degradedLatency,
+		latency:   mpsProbeLatency,
+	}
+
+	ticker := time.NewTicker(m.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Do not report the result of a probe that was cancelled
+			// because the plugin is being stopped.
+			if ctx.Err() != nil {
+				return
+			}
+			monitor.check(health)
+		}
+	}
+}
+
+// mpsHealthMonitor tracks the health of an MPS control daemon based on the
+// result and round-trip time of a probe sent through its control pipe.
+type mpsHealthMonitor struct {
+	resourceName spec.ResourceName
+	probe        func() error
+	devices      func() rm.Devices
+	threshold    time.Duration
+	latency      *latencyHistogram
+	degraded     bool
+}
+
+// check probes the daemon and returns whether it is degraded. A daemon is
+// degraded if the probe fails or takes longer than the threshold. When the
+// daemon becomes degraded or recovers, the devices shared using it are sent to
+// the specified channel. Recovering only clears the MPS failure of a device, so
+// devices that other checks have marked as unhealthy remain unhealthy.
+func (h *mpsHealthMonitor) check(health chan<- *rm.Device) bool {
+	start := time.Now()
+	err := h.probe()
+	latency := time.Since(start)
+	klog.V(5).InfoS("Probed MPS daemon", "resource", h.resourceName, "latency", latency)
+	if h.latency != nil {
+		h.latency.observe(h.resourceName, latency)
+	}
+
+	degraded := err != nil || latency > h.threshold
+	switch {
+	case err != nil && !h.degraded:
+		klog.Warningf("MPS daemon for '%s' is degraded: %v", h.resourceName, err)
+	case degraded && !h.degraded:
+		klog.Warningf("MPS daemon for '%s' is degraded (latency=%v, threshold=%v)", h.resourceName, latency, h.threshold)
+	case !degraded && h.degraded:
+		klog.Infof("MPS daemon for '%s' has recovered (latency=%v)", h.resourceName, latency)
+	}
+
+	switch {
+	case degraded && !h.degraded:
+		for _, d := range h.devices() {
+			health <- d.WithHealth(pluginapi.Unhealthy, rm.HealthReasonMPS)
+		}
+	case !degraded && h.degraded:
+		for _, d := range h.devices() {
+			health <- d.WithHealth(pluginapi.Healthy, rm.HealthReasonMPS)
+		}
+	}
+	h.degraded = degraded
+	return degraded
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
+)
+
+func TestMPSHealthMonitor(t *testing.T) {
+	type probe struct {
+		latency time.Duration
+		err     error
+	}
+	testCases := []struct {
+		description      string
+		probes           []probe
+		expectedDegraded []bool
+		expectedHealth   []string
+	}{
+		{
+			description:      "fast probes are healthy",
+			probes:           []probe{{}, {}},
+			expectedDegraded: []bool{false, false},
+		},
+		{
+			description:      "failed probe is degraded",
+			probes:           []probe{{err: errors.New("no daemon")}},
+			expectedDegraded: []bool{true},
+			expectedHealth:   []string{pluginapi.Unhealthy},
+		},
+		{
+			description:      "slow probe is degraded until it recovers",
+			probes:           []probe{{latency: 20 * time.Millisecond}, {latency: 20 * time.Millisecond}, {}},
+			expectedDegraded: []bool{true, true, false},
+			expectedHealth:   []string{pluginapi.Unhealthy, pluginapi.Healthy},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var i int
+			latency := newLatencyHistogram("latency", "", []float64{1})
+			monitor := &mpsHealthMonitor{
+				resourceName: "nvidia.com/gpu",
+				probe: func() error {
+					p := tc.probes[i]
+					i++
+					time.Sleep(p.latency)
+					return p.err
+				},
+				devices: func() rm.Devices {
+					return rm.Devices{
+						"GPU-0::0": &rm.Device{Device: pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Healthy}},
+					}
+				},
+				threshold: 10 * time.Millisecond,
+				latency:   latency,
+			}
+
+			health := make(chan *rm.Device, len(tc.probes))
+			var degraded []bool
+			for range tc.probes {
+				degraded = append(degraded, monitor.check(health))
+			}
+			close(health)
+			require.Equal(t, tc.expectedDegraded, degraded)
+			require.EqualValues(t, len(tc.probes), latency.series["nvidia.com/gpu"].count)
+
+			var changes []string
+			for d := range health {
+				require.Equal(t, "GPU-0::0", d.ID)
+				require.Equal(t, rm.HealthReasonMPS, d.HealthReason)
+				changes = append(changes, d.Health)
+			}
+			require.Equal(t, tc.expectedHealth, changes)
+		})
+	}
+}
+
+func TestWriteClientConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients", "config.json")
+
+	for _, pids := range [][]int{nil, {1234}} {
+		config := &mpsClientConfig{
+			Resource:               "nvidia.com/gpu",
+			Devices:                []string{"GPU-0::0"},
+			PipeDirectory:          "/mps/nvidia.com/gpu/pipe",
+			ServerPIDs:             pids,
+			ActiveThreadPercentage: "25",
+		}
+		require.NoError(t, writeClientConfig(path, config))
+
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var written mpsClientConfig
+		require.NoError(t, json.Unmarshal(contents, &written))
+		require.Equal(t, *config, written)
+	}
+
+	// Only the config file remains once the temporary files are renamed.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
@@ -76,3 +76,10 @@ func WithImexChannels(imexChannels imex.Channels) Option {
 		m.imexChannels = imexChannels
 	}
 }
+
+// WithKubeletCheckpoint sets the path to the kubelet device manager checkpoint.
+func WithKubeletCheckpoint(path string) Option {
+	return func(m *options) {
+		m.kubeletCheckpoint = path
+	}
+}
@@ -31,6 +31,7 @@ import (
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
 	"github.com/NVIDIA/k8s-device-plugin/internal/cdi"
+	"github.com/NVIDIA/k8s-device-plugin/internal/chaos"
 	"github.com/NVIDIA/k8s-device-plugin/internal/imex"
 	"github.com/NVIDIA/k8s-device-plugin/internal/rm"
 
@@ -46,6 +47,10 @@ const (
 	deviceListEnvVar                          = "NVIDIA_VISIBLE_DEVICES"
 	deviceListAsVolumeMountsHostPath          = "/dev/null"
 	deviceListAsVolumeMountsContainerPathRoot = "/var/run/nvidia-container-devices"
+
+	// drainTimeout defines how long in-flight requests are allowed to complete
+	// when a plugin is stopped before the gRPC server is stopped forcibly.
+	drainTimeout = 10 * time.Second
 )
 
 // nvidiaDevicePlugin implements the Kubernetes device plugin API
@@ -113,7 +118,9 @@ func (plugin *nvidiaDevicePlugin) initialize() {
 }
 
 func (plugin *nvidiaDevicePlugin) cleanup() {
-	close(plugin.stop)
+	if plugin.stop != nil {
+		close(plugin.stop)
+	}
 	plugin.server = nil
 	plugin.health = nil
 	plugin.stop = nil
@@ -129,7 +136,7 @@ func (plugin *nvidiaDevicePlugin) Devices() rm.Devices {
 func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 	plugin.initialize()
 
-	if err := plugin.mps.waitForDaemon(); err != nil {
+	if err := plugin.mps.waitForDaemon(plugin.ctx); err != nil {
 		return fmt.Errorf("error waiting for MPS daemon: %w", err)
 	}
 
@@ -148,8 +155,9 @@ func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 	}
 	klog.Infof("Registered device plugin for '%s' with Kubelet", plugin.rm.Resource())
 
+	go plugin.mps.checkHealth(plugin.stop, plugin.health)
+
 	go func() {
-		// TODO: add MPS health check
 		err := plugin.rm.CheckHealth(plugin.stop, plugin.health)
 		if err != nil {
 			klog.Errorf("Failed to start health check: %v; continuing with health checks disabled", err)
@@ -159,13 +167,22 @@ func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 	return nil
 }
 
-// Stop stops the gRPC server.
+// Stop stops the device plugin.
+// The health checks and ListAndWatch streams are stopped first so that
+// in-flight requests can be drained before the gRPC server is stopped and the
+// plugin socket -- which unregisters the plugin from the kubelet -- is
+// removed.
 func (plugin *nvidiaDevicePlugin) Stop() error {
 	if plugin == nil || plugin.server == nil {
 		return nil
 	}
 	klog.Infof("Stopping to serve '%s' on %s", plugin.rm.Resource(), plugin.socket)
-	plugin.server.Stop()
+	close(plugin.stop)
+
+	plugin.drain(drainTimeout)
+	// The stop channel has already been closed and all requests have
+	// completed, so it can be cleared before cleaning up.
+	plugin.stop = nil
 	if err := os.Remove(plugin.socket); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -173,6 +190,25 @@ func (plugin *nvidiaDevicePlugin) Stop() error {
 	return nil
 }
 
+// drain gracefully stops the gRPC server, waiting for in-flight requests to
+// complete. If they do not complete within the specified timeout, the server
+// is stopped forcibly.
+func (plugin *nvidiaDevicePlugin) drain(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		plugin.server.GracefulStop()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		klog.Warningf("Timed out draining requests for '%s'; stopping", plugin.rm.Resource())
+		plugin.server.Stop()
+		<-done
+	}
+}
+
 // Serve starts the gRPC server of the device plugin.
 func (plugin *nvidiaDevicePlugin) Serve() error {
 	os.Remove(plugin.socket)
@@ -274,9 +310,8 @@ func (plugin *nvidiaDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.D
 		case <-plugin.stop:
 			return nil
 		case d := <-plugin.health:
-			// FIXME: there is no way to recover from the Unhealthy state.
-			d.Health = pluginapi.Unhealthy
-			klog.Infof("'%s' device marked unhealthy: %s", plugin.rm.Resource(), d.ID)
+			health := plugin.rm.SetDeviceHealth(d.ID, d.Health, d.HealthReason)
+			klog.Infof("'%s' device reported %s by %s check, marked %s: %s", plugin.rm.Resource(), d.Health, d.HealthReason, health, d.ID)
 			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
 				return nil
 			}
@@ -286,6 +321,9 @@ func (plugin *nvidiaDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.D
 
 // GetPreferredAllocation returns the preferred allocation from the set of devices specified in the request
 func (plugin *nvidiaDevicePlugin) GetPreferredAllocation(ctx context.Context, r *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	if err := chaos.Inject("GetPreferredAllocation"); err != nil {
+		return nil, err
+	}
 	response := &pluginapi.PreferredAllocationResponse{}
 	for _, req := range r.ContainerRequests {
 		devices, err := plugin.rm.GetPreferredAllocation(req.AvailableDeviceIDs, req.MustIncludeDeviceIDs, int(req.AllocationSize))
@@ -304,21 +342,71 @@ func (plugin *nvidiaDevicePlugin) GetPreferredAllocation(ctx context.Context, r
 
 // Allocate returns a list of devices.
 func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	responses := pluginapi.AllocateResponse{}
+	if err := chaos.Inject("Allocate"); err != nil {
+		return nil, err
+	}
 	for _, req := range reqs.ContainerRequests {
 		if err := plugin.rm.ValidateRequest(req.DevicesIds); err != nil {
 			return nil, fmt.Errorf("invalid allocation request for %q: %w", plugin.rm.Resource(), err)
 		}
+	}
+
+	if err := plugin.waitForFreeMemory(ctx, reqs); err != nil {
+		return nil, err
+	}
+
+	if err := plugin.runAllocateHook(ctx, preAllocateHookStage, plugin.config.Flags.Plugin.PreAllocateHook, reqs, nil); err != nil {
+		return nil, err
+	}
+
+	responses := pluginapi.AllocateResponse{}
+	for _, req := range reqs.ContainerRequests {
 		response, err := plugin.getAllocateResponse(req.DevicesIds)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get allocate response: %v", err)
 		}
+		plugin.applyEnvPolicy(response)
+		// The client configuration records the MPS limits that apply to the
+		// container, so it is written once the env policy has been applied.
+		plugin.mps.updateClientConfig(ctx, response, req.DevicesIds)
 		responses.ContainerResponses = append(responses.ContainerResponses, response)
 	}
 
+	// The allocation has been performed by the time the post-allocate hook
+	// runs, so failing it would not undo the side effects of the allocation,
+	// such as the MPS client configuration that was written.
+	if err := plugin.runAllocateHook(ctx, postAllocateHookStage, plugin.config.Flags.Plugin.PostAllocateHook, reqs, &responses); err != nil {
+		klog.Warningf("Ignoring failure of post-allocate hook for '%s': %v", plugin.rm.Resource(), err)
+	}
+
 	return &responses, nil
 }
 
+// waitForFreeMemory waits for the memory of the requested replicas to be freed
+// by containers that previously used them. This smooths over the turnover of
+// pods sharing a GPU, where the memory of an exited container may not have
+// been released when the next container is allocated.
+func (plugin *nvidiaDevicePlugin) waitForFreeMemory(ctx context.Context, reqs *pluginapi.AllocateRequest) error {
+	wait := plugin.config.Flags.Plugin.AllocateMemoryWait
+	if wait == nil || *wait <= 0 {
+		return nil
+	}
+
+	// All containers are considered together since their replicas may share
+	// the same GPUs.
+	var ids []string
+	for _, req := range reqs.ContainerRequests {
+		ids = append(ids, req.DevicesIds...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*wait))
+	defer cancel()
+	if err := plugin.rm.WaitForFreeMemory(ctx, ids); err != nil {
+		return fmt.Errorf("failed to allocate %q: %w", plugin.rm.Resource(), err)
+	}
+	return nil
+}
+
 func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*pluginapi.ContainerAllocateResponse, error) {
 	deviceIDs := plugin.uniqueDeviceIDsFromAnnotatedDeviceIDs(requestIds)
 
@@ -333,7 +421,7 @@ func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 		}
 	}
 	if plugin.mps.enabled {
-		plugin.updateResponseForMPS(response)
+		plugin.updateResponseForMPS(response, requestIds)
 	}
 
 	if plugin.config.Flags.GDRCopyEnabled != nil && *plugin.config.Flags.GDRCopyEnabled {
@@ -345,6 +433,9 @@ func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 	if plugin.config.Flags.MOFEDEnabled != nil && *plugin.config.Flags.MOFEDEnabled {
 		response.Envs["NVIDIA_MOFED"] = "enabled"
 	}
+	if plugin.config.Flags.Plugin.NCCLTopologyHints != nil && *plugin.config.Flags.Plugin.NCCLTopologyHints {
+		plugin.updateResponseForNCCL(response, requestIds)
+	}
 
 	// The following modifications are only made if at least one non-CDI device
 	// list strategy is selected.
@@ -368,8 +459,21 @@ func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 // updateResponseForMPS ensures that the ContainerAllocate response contains the information required to use MPS.
 // This includes per-resource pipe and log directories as well as a global daemon-specific shm
 // and assumes that an MPS control daemon has already been started.
-func (plugin nvidiaDevicePlugin) updateResponseForMPS(response *pluginapi.ContainerAllocateResponse) {
-	plugin.mps.updateReponse(response)
+func (plugin nvidiaDevicePlugin) updateResponseForMPS(response *pluginapi.ContainerAllocateResponse, requestIds []string) {
+	plugin.mps.updateReponse(response, requestIds)
+}
+
+// updateResponseForNCCL sets the NCCL envvars describing the link topology of the requested devices.
+// Since these envvars are only hints, failing to determine them does not fail the allocation.
+func (plugin *nvidiaDevicePlugin) updateResponseForNCCL(response *pluginapi.ContainerAllocateResponse, requestIds []string) {
+	envs, err := plugin.rm.GetNCCLEnvs(requestIds)
+	if err != nil {
+		klog.Warningf("Failed to determine NCCL topology hints for %v: %v", requestIds, err)
+		return
+	}
+	for k, v := range envs {
+		response.Envs[k] = v
+	}
 }
 
 // updateResponseForCDI updates the specified response for the given device IDs.
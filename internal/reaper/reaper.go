@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package reaper reaps orphaned child processes.
+//
+// When a process runs as PID 1 in a container, processes that are orphaned
+// by their parents -- for example the MPS control daemon after it
+// daemonizes -- are reparented to it. These must be waited for, or they
+// remain as zombies. Since reaping arbitrary children would race with
+// exec.Cmd.Wait, commands started by the process must be started using the
+// functions in this package so that the reaper leaves them alone.
+package reaper
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// reapInterval defines how often the reaper checks for zombies in addition to
+// when a SIGCHLD is received.
+const reapInterval = 30 * time.Second
+
+// children tracks the PIDs of commands that are waited for by their callers.
+var children = struct {
+	sync.Mutex
+	pids map[int]bool
+}{
+	pids: make(map[int]bool),
+}
+
+// Start starts the specified command and registers it so that it is not
+// reaped. Wait must be called for the command to release its resources.
+func Start(cmd *exec.Cmd) error {
+	children.Lock()
+	defer children.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	children.pids[cmd.Process.Pid] = true
+	return nil
+}
+
+// Wait waits for a command that was started using Start to exit.
+func Wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	children.Lock()
+	defer children.Unlock()
+	delete(children.pids, cmd.Process.Pid)
+
+	return err
+}
+
+// Run starts the specified command and waits for it to exit.
+func Run(cmd *exec.Cmd) error {
+	if err := Start(cmd); err != nil {
+		return err
+	}
+	return Wait(cmd)
+}
+
+// CombinedOutput runs the specified command and returns its combined stdout
+// and stderr.
+func CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var b bytes.Buffer
+	cmd.Stdout = &b
+	cmd.Stderr = &b
+	err := Run(cmd)
+	return b.Bytes(), err
+}
+
+// Reap reaps orphaned children until the context is cancelled. Since orphans
+// are only reparented to PID 1, this is a no-op for any other process.
+func Reap(ctx context.Context) {
+	if os.Getpid() != 1 {
+		return
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	defer signal.Stop(sigs)
+
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		reapZombies()
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapZombies waits for each zombie child that was not started using Start.
+func reapZombies() {
+	children.Lock()
+	defer children.Unlock()
+
+	for _, pid := range zombieChildren(os.Getpid()) {
+		if children.pids[pid] {
+			continue
+		}
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+			klog.V(4).Infof("Failed to reap process %d: %v", pid, err)
+			continue
+		}
+		klog.V(4).Infof("Reaped orphaned process %d (status=%d)", pid, status.ExitStatus())
+	}
+}
+
+// zombieChildren returns the PIDs of the zombie children of the specified
+// process.
+func zombieChildren(ppid int) []int {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, stat := range stats {
+		contents, err := os.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		pid, state, parent, ok := parseStat(string(contents))
+		if !ok || state != "Z" || parent != ppid {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// parseStat extracts the PID, state, and parent PID from the contents of a
+// /proc/<pid>/stat file. Since the command name may contain spaces and
+// parentheses, the fields following the last ')' are used.
+func parseStat(stat string) (int, string, int, bool) {
+	open := strings.IndexByte(stat, '(')
+	closing := strings.LastIndexByte(stat, ')')
+	if open < 0 || closing < open {
+		return 0, "", 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	if err != nil {
+		return 0, "", 0, false
+	}
+	fields := strings.Fields(stat[closing+1:])
+	if len(fields) < 2 {
+		return 0, "", 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, "", 0, false
+	}
+	return pid, fields[0], ppid, true
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reaper
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStat(t *testing.T) {
+	testCases := []struct {
+		description   string
+		stat          string
+		expectedPID   int
+		expectedState string
+		expectedPPID  int
+		expectedOK    bool
+	}{
+		{
+			description:   "zombie",
+			stat:          "42 (nvidia-cuda-mp) Z 1 42 42 0 -1 4227148",
+			expectedPID:   42,
+			expectedState: "Z",
+			expectedPPID:  1,
+			expectedOK:    true,
+		},
+		{
+			description:   "command with spaces and parentheses",
+			stat:          "7 (a (b) c) S 3 7 7 0 -1",
+			expectedPID:   7,
+			expectedState: "S",
+			expectedPPID:  3,
+			expectedOK:    true,
+		},
+		{
+			description: "truncated",
+			stat:        "7 (cmd) S",
+		},
+		{
+			description: "invalid",
+			stat:        "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pid, state, ppid, ok := parseStat(tc.stat)
+			require.Equal(t, tc.expectedOK, ok)
+			require.Equal(t, tc.expectedPID, pid)
+			require.Equal(t, tc.expectedState, state)
+			require.Equal(t, tc.expectedPPID, ppid)
+		})
+	}
+}
+
+func TestStartedChildrenAreNotReaped(t *testing.T) {
+	cmd := exec.Command("true")
+	require.NoError(t, Start(cmd))
+	require.Contains(t, children.pids, cmd.Process.Pid)
+
+	// Wait would fail if the reaper had waited for the child.
+	reapZombies()
+	require.NoError(t, Wait(cmd))
+	require.NotContains(t, children.pids, cmd.Process.Pid)
+}
@@ -87,9 +87,19 @@ func NewMigDevice(gi int, ci int, gb uint64, attributes ...map[string]interface{
 	return &resource.DeviceMock{
 		GetNameFunc:       func() (string, error) { return fmt.Sprintf("%dg.%dgb", gi, gb), nil },
 		GetAttributesFunc: func() (map[string]interface{}, error) { return defaultAttributes, nil },
+		GetTotalMemoryMiBFunc: func() (uint64, error) {
+			memory, _ := defaultAttributes["memory"].(uint64)
+			return memory, nil
+		},
 	}
 }
 
+// WithTotalMemoryMiB sets the total memory of the mocked device
+func (d *DeviceMock) WithTotalMemoryMiB(memory uint64) *DeviceMock {
+	d.GetTotalMemoryMiBFunc = func() (uint64, error) { return memory, nil }
+	return d
+}
+
 // WithMigDevices adds the specified MIG devices to the mocked device
 func (d *DeviceMock) WithMigDevices(migs ...*resource.DeviceMock) *DeviceMock {
 	for _, m := range migs {
@@ -17,6 +17,7 @@
 package rm
 
 import (
+	"container/heap"
 	"fmt"
 	"sort"
 )
@@ -24,9 +25,16 @@ import (
 // distributedAlloc returns a list of devices such that any replicated
 // devices are distributed across all replicated GPUs equally. It takes into
 // account already allocated replicas to ensure a proper balance across them.
+//
+// The allocation is deterministic: for the same devices and inputs the same
+// list is returned. Ties between GPUs with the same fraction of allocated
+// replicas are broken by the GPU ID, and the replicas of a GPU are allocated
+// in increasing order of their replica number. The required devices are
+// returned first in the order in which they were specified.
 func (r *resourceManager) distributedAlloc(available, required []string, size int) ([]string, error) {
 	// Get the set of candidate devices as the difference between available and required.
-	candidates := r.devices.Subset(available).Difference(r.devices.Subset(required)).GetIDs()
+	allDevices := r.Devices()
+	candidates := allDevices.Subset(available).Difference(allDevices.Subset(required)).GetIDs()
 	needed := size - len(required)
 
 	if len(candidates) < needed {
@@ -34,16 +42,16 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 	}
 
 	// For each candidate device, build a mapping of (stripped) device ID to
-	// total / available replicas for that device.
-	replicas := make(map[string]*struct{ total, available int })
+	// the replicas of that device and its candidate replicas.
+	replicas := make(map[string]*replicatedDevice)
 	for _, c := range candidates {
 		id := AnnotatedID(c).GetID()
 		if _, exists := replicas[id]; !exists {
-			replicas[id] = &struct{ total, available int }{}
+			replicas[id] = &replicatedDevice{id: id}
 		}
-		replicas[id].available++
+		replicas[id].candidates = append(replicas[id].candidates, c)
 	}
-	for d := range r.devices {
+	for d := range allDevices {
 		id := AnnotatedID(d).GetID()
 		if _, exists := replicas[id]; !exists {
 			continue
@@ -51,26 +59,37 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 		replicas[id].total++
 	}
 
-	// Grab the set of 'needed' devices one-by-one from the candidates list.
-	// Before selecting each candidate, first sort the candidate list using the
-	// replicas map above. After sorting, the first element in the list will
-	// contain the device with the least difference between total and available
-	// replications (based on what's already been allocated). Add this device
-	// to the list of devices to allocate, remove it from the candidate list,
-	// down its available count in the replicas map, and repeat.
+	// Build a priority queue of the devices with candidate replicas. The
+	// device at the head of the queue is the one with the smallest fraction of
+	// its replicas already allocated. Comparing fractions rather than absolute
+	// counts ensures that devices with more replicas (e.g. when replicasPerGiB
+	// is used) receive a proportional share of allocations.
+	queue := make(replicaQueue, 0, len(replicas))
+	for _, rd := range replicas {
+		rd.allocated = rd.total - len(rd.candidates)
+		sort.Slice(rd.candidates, func(i, j int) bool {
+			_, ireplica := AnnotatedID(rd.candidates[i]).Split()
+			_, jreplica := AnnotatedID(rd.candidates[j]).Split()
+			return ireplica < jreplica
+		})
+		queue = append(queue, rd)
+	}
+	heap.Init(&queue)
+
+	// Grab the set of 'needed' devices one-by-one from the head of the queue.
+	// Take the next candidate replica of this device, up its allocated count,
+	// and restore the ordering of the queue.
 	var devices []string
 	for i := 0; i < needed; i++ {
-		sort.Slice(candidates, func(i, j int) bool {
-			iid := AnnotatedID(candidates[i]).GetID()
-			jid := AnnotatedID(candidates[j]).GetID()
-			idiff := replicas[iid].total - replicas[iid].available
-			jdiff := replicas[jid].total - replicas[jid].available
-			return idiff < jdiff
-		})
-		id := AnnotatedID(candidates[0]).GetID()
-		replicas[id].available--
-		devices = append(devices, candidates[0])
-		candidates = candidates[1:]
+		rd := queue[0]
+		devices = append(devices, rd.candidates[0])
+		rd.candidates = rd.candidates[1:]
+		rd.allocated++
+		if len(rd.candidates) == 0 {
+			heap.Pop(&queue)
+			continue
+		}
+		heap.Fix(&queue, 0)
 	}
 
 	// Add the set of required devices to this list and return it.
@@ -78,3 +97,42 @@ func (r *resourceManager) distributedAlloc(available, required []string, size in
 
 	return devices, nil
 }
+
+// replicatedDevice tracks the allocation state of the replicas of a device.
+type replicatedDevice struct {
+	id         string
+	total      int
+	allocated  int
+	candidates []string
+}
+
+// replicaQueue implements heap.Interface for a set of replicated devices. The
+// devices are ordered by the fraction of their replicas that are allocated,
+// with ties broken by device ID.
+type replicaQueue []*replicatedDevice
+
+func (q replicaQueue) Len() int { return len(q) }
+
+func (q replicaQueue) Less(i, j int) bool {
+	// Compare allocated[i]/total[i] < allocated[j]/total[j] without division.
+	li := q[i].allocated * q[j].total
+	lj := q[j].allocated * q[i].total
+	if li != lj {
+		return li < lj
+	}
+	return q[i].id < q[j].id
+}
+
+func (q replicaQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *replicaQueue) Push(x any) {
+	*q = append(*q, x.(*replicatedDevice))
+}
+
+func (q *replicaQueue) Pop() any {
+	old := *q
+	n := len(old)
+	rd := old[n-1]
+	*q = old[:n-1]
+	return rd
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// newReplicatedDevices creates the specified number of replicas for each GPU.
+func newReplicatedDevices(replicas map[string]int) Devices {
+	devices := make(Devices)
+	for uuid, n := range replicas {
+		for i := 0; i < n; i++ {
+			id := string(NewAnnotatedID(uuid, i))
+			devices[id] = &Device{Device: pluginapi.Device{ID: id}, Replicas: n}
+		}
+	}
+	return devices
+}
+
+func TestDistributedAlloc(t *testing.T) {
+	testCases := []struct {
+		description     string
+		replicas        map[string]int
+		allocated       []string
+		required        []string
+		size            int
+		expectedDevices []string
+		expectedError   bool
+	}{
+		{
+			description:     "replicas are distributed across GPUs",
+			replicas:        map[string]int{"GPU-0": 4, "GPU-1": 4},
+			size:            4,
+			expectedDevices: []string{"GPU-0::0", "GPU-1::0", "GPU-0::1", "GPU-1::1"},
+		},
+		{
+			description:     "least allocated GPU is preferred",
+			replicas:        map[string]int{"GPU-0": 4, "GPU-1": 4},
+			allocated:       []string{"GPU-0::0", "GPU-0::1"},
+			size:            3,
+			expectedDevices: []string{"GPU-1::0", "GPU-1::1", "GPU-0::2"},
+		},
+		{
+			description:     "allocations are proportional to the number of replicas",
+			replicas:        map[string]int{"GPU-0": 4, "GPU-1": 8},
+			size:            6,
+			expectedDevices: []string{"GPU-0::0", "GPU-1::0", "GPU-1::1", "GPU-0::1", "GPU-1::2", "GPU-1::3"},
+		},
+		{
+			description:     "required devices are included",
+			replicas:        map[string]int{"GPU-0": 2, "GPU-1": 2},
+			required:        []string{"GPU-1::1"},
+			size:            2,
+			expectedDevices: []string{"GPU-1::1", "GPU-0::0"},
+		},
+		{
+			description:     "exhausted GPU is skipped",
+			replicas:        map[string]int{"GPU-0": 1, "GPU-1": 4},
+			size:            3,
+			expectedDevices: []string{"GPU-0::0", "GPU-1::0", "GPU-1::1"},
+		},
+		{
+			description:   "not enough devices",
+			replicas:      map[string]int{"GPU-0": 2},
+			size:          3,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices := newReplicatedDevices(tc.replicas)
+			r := &resourceManager{devices: newDeviceSnapshot(devices)}
+			available := devices.Difference(devices.Subset(tc.allocated)).GetIDs()
+
+			allocated, err := r.distributedAlloc(available, tc.required, tc.size)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDevices, allocated)
+		})
+	}
+}
+
+func TestDistributedAllocIsDeterministic(t *testing.T) {
+	devices := newReplicatedDevices(map[string]int{"GPU-0": 3, "GPU-1": 3, "GPU-2": 6})
+	r := &tegraResourceManager{
+		resourceManager: resourceManager{devices: newDeviceSnapshot(devices)},
+	}
+	available := devices.Difference(devices.Subset([]string{"GPU-2::0"})).GetIDs()
+	expected := []string{"GPU-0::0", "GPU-1::0", "GPU-2::1", "GPU-0::1", "GPU-1::1"}
+
+	for i := 0; i < 20; i++ {
+		rand.Shuffle(len(available), func(i, j int) {
+			available[i], available[j] = available[j], available[i]
+		})
+		allocated, err := r.GetPreferredAllocation(available, nil, len(expected))
+		require.NoError(t, err)
+		require.Equal(t, expected, allocated)
+	}
+}
+
+func BenchmarkDistributedAlloc(b *testing.B) {
+	replicas := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		replicas[fmt.Sprintf("GPU-%d", i)] = 100
+	}
+	devices := newReplicatedDevices(replicas)
+	r := &resourceManager{devices: newDeviceSnapshot(devices)}
+	available := devices.GetIDs()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := r.distributedAlloc(available, nil, len(available)/2)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// KubeletCheckpointFile is the name of the file in which the kubelet device
+// manager checkpoints its allocations. It is stored alongside the kubelet
+// socket.
+const KubeletCheckpointFile = "kubelet_internal_checkpoint"
+
+// kubeletCheckpoint represents the subset of the kubelet device manager
+// checkpoint that is required to determine which device IDs are allocated.
+type kubeletCheckpoint struct {
+	Data struct {
+		PodDeviceEntries []struct {
+			ResourceName string `json:"ResourceName"`
+			// DeviceIDs is a map of NUMA node to device IDs in current
+			// kubelet versions and a list of device IDs in versions before
+			// v1.20.
+			DeviceIDs json.RawMessage `json:"DeviceIDs"`
+		} `json:"PodDeviceEntries"`
+	} `json:"Data"`
+}
+
+// ReadCheckpointedDeviceIDs returns the device IDs of each resource that the
+// kubelet has checkpointed as being allocated to pods.
+func ReadCheckpointedDeviceIDs(path string) (map[spec.ResourceName][]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubelet checkpoint: %w", err)
+	}
+
+	var checkpoint kubeletCheckpoint
+	if err := json.Unmarshal(contents, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse kubelet checkpoint: %w", err)
+	}
+
+	ids := make(map[spec.ResourceName][]string)
+	for _, entry := range checkpoint.Data.PodDeviceEntries {
+		resource := spec.ResourceName(entry.ResourceName)
+		var perNUMA map[string][]string
+		if err := json.Unmarshal(entry.DeviceIDs, &perNUMA); err == nil {
+			for _, numaIDs := range perNUMA {
+				ids[resource] = append(ids[resource], numaIDs...)
+			}
+			continue
+		}
+		var legacy []string
+		if err := json.Unmarshal(entry.DeviceIDs, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to parse device IDs for %v: %w", entry.ResourceName, err)
+		}
+		ids[resource] = append(ids[resource], legacy...)
+	}
+	return ids, nil
+}
+
+// adoptCheckpointedIDs renames replicas so that the specified IDs, which the
+// kubelet has checkpointed as allocated, remain known to the plugin.
+//
+// Replica IDs are derived from the UUID of the GPU and the replica number.
+// If the number of replicas of a GPU changes across a restart, IDs that are
+// still allocated to running pods may no longer be generated. For each such
+// ID, an unallocated replica of the same GPU is renamed to the checkpointed ID
+// so that the kubelet's accounting remains consistent with the devices that
+// are advertised. A map of each adopted ID to the generated ID it replaces is
+// returned.
+//
+// Since the devices may be part of a snapshot, renamed devices are replaced
+// with modified copies instead of being updated in place.
+func (ds Devices) adoptCheckpointedIDs(ids []string) map[string]string {
+	allocated := make(map[string]bool)
+	for _, id := range ids {
+		allocated[id] = true
+	}
+
+	// Candidates are the unallocated replicas of each GPU, with the highest
+	// replica numbers first so that the IDs that are most likely to be
+	// removed on a subsequent restart are renamed.
+	candidates := make(map[string][]*Device)
+	for _, d := range ds {
+		if !AnnotatedID(d.ID).HasAnnotations() || allocated[d.ID] {
+			continue
+		}
+		candidates[d.GetUUID()] = append(candidates[d.GetUUID()], d)
+	}
+	for _, c := range candidates {
+		sort.Slice(c, func(i, j int) bool {
+			_, ireplica := AnnotatedID(c[i].ID).Split()
+			_, jreplica := AnnotatedID(c[j].ID).Split()
+			return ireplica > jreplica
+		})
+	}
+
+	renamed := make(map[string]string)
+	for _, id := range slices.Sorted(slices.Values(ids)) {
+		if ds.Contains(id) || !AnnotatedID(id).HasAnnotations() {
+			continue
+		}
+		uuid := AnnotatedID(id).GetID()
+		if len(candidates[uuid]) == 0 {
+			klog.Warningf("Unable to adopt checkpointed device ID %v: no unallocated replica available", id)
+			continue
+		}
+		d := candidates[uuid][0]
+		candidates[uuid] = candidates[uuid][1:]
+
+		klog.Infof("Adopting checkpointed device ID %v for replica %v", id, d.ID)
+		adopted := d.clone()
+		adopted.ID = id
+		delete(ds, d.ID)
+		ds[id] = adopted
+		renamed[id] = d.ID
+	}
+	return renamed
+}
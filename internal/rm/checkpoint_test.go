@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestReadCheckpointedDeviceIDs(t *testing.T) {
+	testCases := []struct {
+		description   string
+		contents      string
+		expectedIDs   map[spec.ResourceName][]string
+		expectedError bool
+	}{
+		{
+			description: "per NUMA node device IDs",
+			contents: `{"Data":{"PodDeviceEntries":[
+				{"PodUID":"a","ContainerName":"c","ResourceName":"nvidia.com/gpu","DeviceIDs":{"0":["GPU-0::1"],"1":["GPU-1::3"]},"AllocResp":""},
+				{"PodUID":"b","ContainerName":"c","ResourceName":"example.com/other","DeviceIDs":{"-1":["dev0"]},"AllocResp":""}
+			],"RegisteredDevices":{}},"Checksum":1}`,
+			expectedIDs: map[spec.ResourceName][]string{
+				"nvidia.com/gpu":    {"GPU-0::1", "GPU-1::3"},
+				"example.com/other": {"dev0"},
+			},
+		},
+		{
+			description: "legacy device IDs",
+			contents:    `{"Data":{"PodDeviceEntries":[{"ResourceName":"nvidia.com/gpu","DeviceIDs":["GPU-0::1"]}]}}`,
+			expectedIDs: map[spec.ResourceName][]string{
+				"nvidia.com/gpu": {"GPU-0::1"},
+			},
+		},
+		{
+			description:   "invalid checkpoint",
+			contents:      `{"Data":`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), KubeletCheckpointFile)
+			require.NoError(t, os.WriteFile(path, []byte(tc.contents), 0600))
+
+			ids, err := ReadCheckpointedDeviceIDs(path)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for resource := range tc.expectedIDs {
+				require.ElementsMatch(t, tc.expectedIDs[resource], ids[resource])
+			}
+			require.Len(t, ids, len(tc.expectedIDs))
+		})
+	}
+}
+
+func TestAdoptCheckpointedIDs(t *testing.T) {
+	testCases := []struct {
+		description     string
+		replicas        int
+		checkpointed    []string
+		expectedIDs     []string
+		expectedRenamed map[string]string
+	}{
+		{
+			description:     "all checkpointed IDs exist",
+			replicas:        4,
+			checkpointed:    []string{"GPU-0::1"},
+			expectedIDs:     []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-0::3"},
+			expectedRenamed: map[string]string{},
+		},
+		{
+			description:  "replicas reduced",
+			replicas:     4,
+			checkpointed: []string{"GPU-0::3", "GPU-0::5", "GPU-0::7"},
+			expectedIDs:  []string{"GPU-0::0", "GPU-0::3", "GPU-0::5", "GPU-0::7"},
+			expectedRenamed: map[string]string{
+				"GPU-0::5": "GPU-0::2",
+				"GPU-0::7": "GPU-0::1",
+			},
+		},
+		{
+			description:     "no unallocated replicas",
+			replicas:        2,
+			checkpointed:    []string{"GPU-0::0", "GPU-0::1", "GPU-0::2"},
+			expectedIDs:     []string{"GPU-0::0", "GPU-0::1"},
+			expectedRenamed: map[string]string{},
+		},
+		{
+			description:     "other GPUs are ignored",
+			replicas:        2,
+			checkpointed:    []string{"GPU-1::4"},
+			expectedIDs:     []string{"GPU-0::0", "GPU-0::1"},
+			expectedRenamed: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices := make(Devices)
+			for i := 0; i < tc.replicas; i++ {
+				id := string(NewAnnotatedID("GPU-0", i))
+				devices[id] = &Device{Device: pluginapi.Device{ID: id}, Index: "0", Replicas: tc.replicas}
+			}
+
+			renamed := devices.adoptCheckpointedIDs(tc.checkpointed)
+
+			require.EqualValues(t, tc.expectedRenamed, renamed)
+			require.ElementsMatch(t, tc.expectedIDs, devices.GetIDs())
+			for id, d := range devices {
+				require.Equal(t, id, d.ID)
+			}
+		})
+	}
+}
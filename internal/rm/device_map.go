@@ -252,7 +252,9 @@ func (d DeviceMap) getIDsOfDevicesToReplicate(r *spec.ReplicatedResource) ([]str
 		if r.Devices.Count > len(devices) {
 			return nil, fmt.Errorf("requested %d devices to be replicated, but only %d devices available", r.Devices.Count, len(devices))
 		}
-		return devices.GetIDs()[:r.Devices.Count], nil
+		// The devices are ordered by index so that the same devices are
+		// selected, and the same replica IDs generated, across restarts.
+		return devices.getIDsSortedByIndex()[:r.Devices.Count], nil
 	}
 
 	// If a specific set of devices for this resource type are to be replicated.
@@ -323,9 +325,10 @@ func updateDeviceMapWithReplicas(replicatedResources *spec.ReplicatedResources,
 			name = r.Rename
 		}
 		for _, id := range ids {
-			for i := 0; i < r.Replicas; i++ {
+			original := oDevices[r.Name][id]
+			replicas := r.ReplicasFor(original.TotalMemory)
+			for i := 0; i < replicas; i++ {
 				annotatedID := string(NewAnnotatedID(id, i))
-				original := oDevices[r.Name][id]
 				replicatedDevice := Device{
 					Device: pluginapi.Device{
 						ID:       annotatedID,
@@ -336,7 +339,7 @@ func updateDeviceMapWithReplicas(replicatedResources *spec.ReplicatedResources,
 					Index:             original.Index,
 					TotalMemory:       original.TotalMemory,
 					ComputeCapability: original.ComputeCapability,
-					Replicas:          r.Replicas,
+					Replicas:          replicas,
 				}
 				devices.insert(name, &replicatedDevice)
 			}
@@ -107,3 +107,50 @@ func TestDeviceMapInsert(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateDeviceMapWithReplicasPerGiB(t *testing.T) {
+	small := Device{Device: pluginapi.Device{ID: "GPU-0"}, Index: "0", TotalMemory: 24 << 30}
+	large := Device{Device: pluginapi.Device{ID: "GPU-1"}, Index: "1", TotalMemory: 80 << 30}
+
+	replicatedResources := &spec.ReplicatedResources{
+		Resources: []spec.ReplicatedResource{
+			{
+				Name:           "nvidia.com/gpu",
+				Devices:        spec.ReplicatedDevices{All: true},
+				ReplicasPerGiB: 0.25,
+			},
+		},
+	}
+	deviceMap := DeviceMap{
+		"nvidia.com/gpu": Devices{
+			small.ID: &small,
+			large.ID: &large,
+		},
+	}
+
+	devices, err := updateDeviceMapWithReplicas(replicatedResources, deviceMap)
+	require.NoError(t, err)
+
+	replicas := make(map[string]int)
+	for _, d := range devices["nvidia.com/gpu"] {
+		replicas[d.GetUUID()]++
+		require.Equal(t, d.Replicas, int(d.TotalMemory>>30)/4)
+	}
+	require.Equal(t, map[string]int{"GPU-0": 6, "GPU-1": 20}, replicas)
+}
+
+func TestGetIDsOfDevicesToReplicateCountIsStable(t *testing.T) {
+	devices := make(Devices)
+	for _, index := range []string{"10", "2", "0", "1"} {
+		id := "GPU-" + index
+		devices[id] = &Device{Device: pluginapi.Device{ID: id}, Index: index}
+	}
+	deviceMap := DeviceMap{"nvidia.com/gpu": devices}
+
+	ids, err := deviceMap.getIDsOfDevicesToReplicate(&spec.ReplicatedResource{
+		Name:    "nvidia.com/gpu",
+		Devices: spec.ReplicatedDevices{Count: 3},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"GPU-0", "GPU-1", "GPU-2"}, ids)
+}
@@ -18,6 +18,8 @@ package rm
 
 import (
 	"fmt"
+	"maps"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -35,8 +37,28 @@ type Device struct {
 	// Replicas stores the total number of times this device is replicated.
 	// If this is 0 or 1 then the device is not shared.
 	Replicas int
+	// HealthReason identifies the source of the health of a device that is
+	// sent as a health update. See WithHealth.
+	HealthReason HealthReason
+	// unhealthy stores the sources that currently report the device as
+	// unhealthy.
+	unhealthy map[HealthReason]bool
 }
 
+// HealthReason identifies a source that reports the health of a device. A
+// device is only healthy if none of the sources report it as unhealthy, so
+// that one source cannot mark a device as healthy that another source has
+// marked as unhealthy.
+type HealthReason string
+
+// Constants representing the sources of device health updates
+const (
+	HealthReasonXID      = HealthReason("xid")
+	HealthReasonFabric   = HealthReason("fabric")
+	HealthReasonThrottle = HealthReason("throttle")
+	HealthReasonMPS      = HealthReason("mps")
+)
+
 // deviceInfo defines the information the required to construct a Device
 type deviceInfo interface {
 	GetUUID() (string, error)
@@ -103,6 +125,54 @@ func BuildDevice(index string, d deviceInfo) (*Device, error) {
 	return &dev, nil
 }
 
+// WithHealth returns a copy of the device with the specified health as
+// reported by the specified source. Health checks send such copies to report
+// a change in the health of a device.
+func (d *Device) WithHealth(health string, reason HealthReason) *Device {
+	updated := d.clone()
+	updated.Health = health
+	updated.HealthReason = reason
+	return updated
+}
+
+// setHealth records the health of the device as reported by the specified
+// source. The device is unhealthy as long as any source reports it as
+// unhealthy.
+func (d *Device) setHealth(health string, reason HealthReason) {
+	if health == pluginapi.Healthy {
+		delete(d.unhealthy, reason)
+	} else {
+		if d.unhealthy == nil {
+			d.unhealthy = make(map[HealthReason]bool)
+		}
+		d.unhealthy[reason] = true
+	}
+
+	d.Health = pluginapi.Healthy
+	if len(d.unhealthy) > 0 {
+		d.Health = pluginapi.Unhealthy
+	}
+}
+
+// clone returns a copy of the device. The embedded pluginapi.Device is copied
+// field by field since protobuf messages must not be copied by value.
+func (d *Device) clone() *Device {
+	return &Device{
+		Device: pluginapi.Device{
+			ID:       d.ID,
+			Health:   d.Health,
+			Topology: d.Topology,
+		},
+		Paths:             d.Paths,
+		Index:             d.Index,
+		TotalMemory:       d.TotalMemory,
+		ComputeCapability: d.ComputeCapability,
+		Replicas:          d.Replicas,
+		HealthReason:      d.HealthReason,
+		unhealthy:         maps.Clone(d.unhealthy),
+	}
+}
+
 // Contains checks if Devices contains devices matching all ids.
 func (ds Devices) Contains(ids ...string) bool {
 	for _, id := range ids {
@@ -119,8 +189,9 @@ func (ds Devices) GetByID(id string) *Device {
 }
 
 // GetByIndex returns a reference to the device matching the specified Index (nil otherwise).
+// If multiple devices (e.g. replicas) match, the one with the lowest ID is returned.
 func (ds Devices) GetByIndex(index string) *Device {
-	for _, d := range ds {
+	for _, d := range ds.sorted() {
 		if d.Index == index {
 			return d
 		}
@@ -151,20 +222,66 @@ func (ds Devices) Difference(ods Devices) Devices {
 	return res
 }
 
+// sorted returns the devices in the Devices ordered by their ID.
+//
+// Since map iteration order is random, all functions that return a slice
+// derived from Devices iterate over the devices in this order so that their
+// results, and any allocations based on them, are reproducible.
+func (ds Devices) sorted() []*Device {
+	res := make([]*Device, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
 // GetIDs returns the ids from all devices in the Devices
 func (ds Devices) GetIDs() []string {
 	var res []string
-	for _, d := range ds {
+	for _, d := range ds.sorted() {
 		res = append(res, d.ID)
 	}
 	return res
 }
 
+// getIDsSortedByIndex returns the ids from all devices in the Devices ordered
+// by their index. MIG devices are ordered by their parent GPU index first.
+func (ds Devices) getIDsSortedByIndex() []string {
+	ids := ds.GetIDs()
+	sort.SliceStable(ids, func(i, j int) bool {
+		return indexLess(ds[ids[i]].Index, ds[ids[j]].Index)
+	})
+	return ids
+}
+
+// indexLess compares two device indices of the form 'i' or 'i:j' numerically.
+func indexLess(a, b string) bool {
+	as := strings.Split(a, ":")
+	bs := strings.Split(b, ":")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		ai, aerr := strconv.Atoi(as[k])
+		bi, berr := strconv.Atoi(bs[k])
+		if aerr != nil || berr != nil {
+			if as[k] != bs[k] {
+				return as[k] < bs[k]
+			}
+			continue
+		}
+		if ai != bi {
+			return ai < bi
+		}
+	}
+	return len(as) < len(bs)
+}
+
 // GetUUIDs returns the uuids associated with the Device in the set.
 func (ds Devices) GetUUIDs() []string {
 	var res []string
 	seen := make(map[string]bool)
-	for _, d := range ds {
+	for _, d := range ds.sorted() {
 		uuid := d.GetUUID()
 		if seen[uuid] {
 			continue
@@ -178,8 +295,7 @@ func (ds Devices) GetUUIDs() []string {
 // GetPluginDevices returns the plugin Devices from all devices in the Devices
 func (ds Devices) GetPluginDevices() []*pluginapi.Device {
 	var res []*pluginapi.Device
-	for _, device := range ds {
-		d := device
+	for _, d := range ds.sorted() {
 		res = append(res, &d.Device)
 	}
 	return res
@@ -188,7 +304,7 @@ func (ds Devices) GetPluginDevices() []*pluginapi.Device {
 // GetIndices returns the Indices from all devices in the Devices
 func (ds Devices) GetIndices() []string {
 	var res []string
-	for _, d := range ds {
+	for _, d := range ds.sorted() {
 		res = append(res, d.Index)
 	}
 	return res
@@ -197,7 +313,7 @@ func (ds Devices) GetIndices() []string {
 // GetPaths returns the Paths from all devices in the Devices
 func (ds Devices) GetPaths() []string {
 	var res []string
-	for _, d := range ds {
+	for _, d := range ds.sorted() {
 		res = append(res, d.Paths...)
 	}
 	return res
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestDevicesAreOrderedByID(t *testing.T) {
+	devices := Devices{
+		"GPU-b::1": {Device: pluginapi.Device{ID: "GPU-b::1"}, Index: "0", Paths: []string{"/dev/nvidia0"}},
+		"GPU-a::0": {Device: pluginapi.Device{ID: "GPU-a::0"}, Index: "1", Paths: []string{"/dev/nvidia1"}},
+		"GPU-b::0": {Device: pluginapi.Device{ID: "GPU-b::0"}, Index: "0", Paths: []string{"/dev/nvidia0"}},
+	}
+
+	// Repeat the checks since map iteration order is random.
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []string{"GPU-a::0", "GPU-b::0", "GPU-b::1"}, devices.GetIDs())
+		require.Equal(t, []string{"GPU-a", "GPU-b"}, devices.GetUUIDs())
+		require.Equal(t, []string{"1", "0", "0"}, devices.GetIndices())
+		require.Equal(t, []string{"/dev/nvidia1", "/dev/nvidia0", "/dev/nvidia0"}, devices.GetPaths())
+		require.Equal(t, "GPU-b::0", devices.GetByIndex("0").ID)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// defaultFabricReadyTimeout defines how long the fabric of a GPU may take to
+// become ready before this is reported as a failure if no timeout is
+// configured.
+const defaultFabricReadyTimeout = 5 * time.Minute
+
+// markFabricNotReady marks the devices on GPUs whose fabric is not ready as
+// unhealthy so that they are not advertised as usable. On systems where GPUs
+// are connected through NVSwitches (e.g. HGX), the fabric manager must
+// complete the registration of a GPU before workloads using NCCL can run on
+// it. The UUIDs of the affected GPUs are returned so that the health checks
+// can restore their devices once the fabric is ready.
+func (r *nvmlResourceManager) markFabricNotReady() map[string]bool {
+	notReady := make(map[string]bool)
+	checked := make(map[string]bool)
+	for _, d := range r.Devices() {
+		uuid, _, _, err := r.getDevicePlacement(d)
+		if err != nil {
+			klog.Warningf("Could not determine device placement for %v: %v; skipping fabric check.", d.ID, err)
+			continue
+		}
+		if !checked[uuid] {
+			checked[uuid] = true
+			ready, err := r.isFabricReady(uuid)
+			if err != nil {
+				klog.Warningf("Fabric check failed for %v: %v", uuid, err)
+			}
+			if !ready {
+				klog.Infof("The GPU fabric of %v is not ready; marking its devices unhealthy until it is.", uuid)
+				notReady[uuid] = true
+			}
+		}
+		if notReady[uuid] {
+			r.SetDeviceHealth(d.ID, pluginapi.Unhealthy, HealthReasonFabric)
+		}
+	}
+	return notReady
+}
+
+// fabricReadyTimeout returns the configured fabric ready timeout.
+func (r *nvmlResourceManager) fabricReadyTimeout() time.Duration {
+	if r.config == nil || r.config.Flags.Plugin == nil || r.config.Flags.Plugin.FabricReadyTimeout == nil {
+		return defaultFabricReadyTimeout
+	}
+	return time.Duration(*r.config.Flags.Plugin.FabricReadyTimeout)
+}
+
+// isFabricReady checks whether the fabric registration of the GPU with the
+// specified UUID has completed.
+func (r *nvmlResourceManager) isFabricReady(uuid string) (bool, error) {
+	gpu, ret := r.nvml.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("unable to get device handle from UUID: %v", ret)
+	}
+	return isFabricReady(gpu)
+}
+
+// isFabricReady checks whether the fabric registration of the specified GPU
+// has completed. GPUs that are not attached to a fabric are always considered
+// ready.
+func isFabricReady(gpu nvml.Device) (bool, error) {
+	info, ret := gpu.GetGpuFabricInfo()
+	if ret == nvml.ERROR_NOT_SUPPORTED || ret == nvml.ERROR_FUNCTION_NOT_FOUND {
+		return true, nil
+	}
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("failed to get GPU fabric info: %v", ret)
+	}
+
+	switch info.State {
+	case nvml.GPU_FABRIC_STATE_NOT_SUPPORTED:
+		return true, nil
+	case nvml.GPU_FABRIC_STATE_COMPLETED:
+		//nolint:gosec  // The fabric status is an nvmlReturn_t value.
+		if status := nvml.Return(info.Status); status != nvml.SUCCESS {
+			return false, fmt.Errorf("fabric registration failed: %v", status)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// fabricChecker tracks the GPUs whose fabric was not ready when the resource
+// manager was created and restores their devices once it is.
+type fabricChecker struct {
+	start   time.Time
+	timeout time.Duration
+	gpus    map[string]*fabricGPU
+}
+
+// fabricGPU stores the fabric state of a single GPU and the devices that it
+// backs.
+type fabricGPU struct {
+	gpu     nvml.Device
+	devices []*Device
+	// reported indicates whether the GPU has been reported as not becoming
+	// ready within the timeout.
+	reported bool
+}
+
+// newFabricChecker creates a fabric checker. GPUs whose fabric is not ready
+// within the specified timeout of the start time are reported as failed.
+func newFabricChecker(start time.Time, timeout time.Duration) *fabricChecker {
+	return &fabricChecker{
+		start:   start,
+		timeout: timeout,
+		gpus:    make(map[string]*fabricGPU),
+	}
+}
+
+// add registers a device backed by the GPU with the specified UUID, whose
+// fabric is not ready.
+func (c *fabricChecker) add(uuid string, gpu nvml.Device, d *Device) {
+	if c.gpus[uuid] == nil {
+		c.gpus[uuid] = &fabricGPU{gpu: gpu}
+	}
+	c.gpus[uuid].devices = append(c.gpus[uuid].devices, d)
+}
+
+// check queries the fabric state of each GPU whose fabric is not ready and
+// clears the fabric failure of its devices once it is. Devices that other
+// checks have marked as unhealthy remain unhealthy. GPUs are no longer checked
+// once their fabric is ready.
+func (c *fabricChecker) check(now time.Time, health chan<- *Device) {
+	for uuid, g := range c.gpus {
+		ready, err := isFabricReady(g.gpu)
+		if ready {
+			for _, d := range g.devices {
+				klog.Infof("The GPU fabric of %v is ready; clearing the fabric failure of device %v.", uuid, d.ID)
+				health <- d.WithHealth(pluginapi.Healthy, HealthReasonFabric)
+			}
+			delete(c.gpus, uuid)
+			continue
+		}
+		if g.reported || now.Sub(c.start) < c.timeout {
+			continue
+		}
+		g.reported = true
+		if err != nil {
+			klog.Warningf("The GPU fabric of %v is not ready after %v: %v; its devices remain unhealthy until it is.", uuid, c.timeout, err)
+		} else {
+			klog.Warningf("The GPU fabric of %v is not ready after %v; its devices remain unhealthy until it is.", uuid, c.timeout)
+		}
+	}
+}
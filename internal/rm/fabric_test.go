@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+type fabricTestNvml struct {
+	nvml.Interface
+	devices map[string]*fabricTestDevice
+}
+
+func (n *fabricTestNvml) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return) {
+	d, ok := n.devices[uuid]
+	if !ok {
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+	return d, nvml.SUCCESS
+}
+
+// fabricTestDevice returns the fabric states in order, repeating the last one.
+type fabricTestDevice struct {
+	nvml.Device
+	ret    nvml.Return
+	states []uint8
+	status nvml.Return
+}
+
+func (d *fabricTestDevice) GetGpuFabricInfo() (nvml.GpuFabricInfo, nvml.Return) {
+	state := d.states[0]
+	if len(d.states) > 1 {
+		d.states = d.states[1:]
+	}
+	return nvml.GpuFabricInfo{State: state, Status: uint32(d.status)}, d.ret
+}
+
+func TestMarkFabricNotReady(t *testing.T) {
+	testCases := []struct {
+		description      string
+		device           *fabricTestDevice
+		expectedNotReady bool
+	}{
+		{
+			description: "fabric not supported",
+			device:      &fabricTestDevice{ret: nvml.ERROR_NOT_SUPPORTED, states: []uint8{0}},
+		},
+		{
+			description: "fabric state not supported",
+			device:      &fabricTestDevice{states: []uint8{nvml.GPU_FABRIC_STATE_NOT_SUPPORTED}},
+		},
+		{
+			description: "fabric ready",
+			device:      &fabricTestDevice{states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}},
+		},
+		{
+			description:      "fabric in progress",
+			device:           &fabricTestDevice{states: []uint8{nvml.GPU_FABRIC_STATE_IN_PROGRESS}},
+			expectedNotReady: true,
+		},
+		{
+			description:      "fabric registration failed",
+			device:           &fabricTestDevice{states: []uint8{nvml.GPU_FABRIC_STATE_COMPLETED}, status: nvml.ERROR_UNKNOWN},
+			expectedNotReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices := Devices{
+				"GPU-0::0": &Device{Device: pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Healthy}},
+				"GPU-0::1": &Device{Device: pluginapi.Device{ID: "GPU-0::1", Health: pluginapi.Healthy}},
+			}
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					devices: newDeviceSnapshot(devices),
+				},
+				nvml: &fabricTestNvml{
+					devices: map[string]*fabricTestDevice{"GPU-0": tc.device},
+				},
+			}
+
+			notReady := r.markFabricNotReady()
+
+			require.Equal(t, tc.expectedNotReady, notReady["GPU-0"])
+			for _, d := range r.Devices() {
+				require.Equal(t, tc.expectedNotReady, d.Health == pluginapi.Unhealthy, d.ID)
+			}
+		})
+	}
+}
+
+func TestFabricChecker(t *testing.T) {
+	start := time.Now()
+	device := &fabricTestDevice{
+		states: []uint8{
+			nvml.GPU_FABRIC_STATE_IN_PROGRESS,
+			nvml.GPU_FABRIC_STATE_IN_PROGRESS,
+			nvml.GPU_FABRIC_STATE_COMPLETED,
+		},
+	}
+	checker := newFabricChecker(start, time.Minute)
+	checker.add("GPU-0", device, &Device{Device: pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Unhealthy}})
+	checker.add("GPU-0", device, &Device{Device: pluginapi.Device{ID: "GPU-0::1", Health: pluginapi.Unhealthy}})
+
+	check := func(now time.Time) map[string]string {
+		health := make(chan *Device, 2)
+		checker.check(now, health)
+		close(health)
+		updated := make(map[string]string)
+		for d := range health {
+			updated[d.ID] = d.Health
+		}
+		return updated
+	}
+
+	// The devices remain unhealthy while the fabric is not ready, including
+	// after the timeout.
+	require.Empty(t, check(start))
+	require.Empty(t, check(start.Add(2*time.Minute)))
+	require.True(t, checker.gpus["GPU-0"].reported)
+
+	// The devices are restored once the fabric is ready.
+	require.Equal(t, map[string]string{"GPU-0::0": pluginapi.Healthy, "GPU-0::1": pluginapi.Healthy}, check(start.Add(3*time.Minute)))
+	require.Empty(t, checker.gpus)
+	require.Empty(t, check(start.Add(4*time.Minute)))
+}
+
+func TestFabricReadyKeepsXIDFailure(t *testing.T) {
+	device := &fabricTestDevice{
+		states: []uint8{
+			nvml.GPU_FABRIC_STATE_IN_PROGRESS,
+			nvml.GPU_FABRIC_STATE_COMPLETED,
+		},
+	}
+	r := &nvmlResourceManager{
+		resourceManager: resourceManager{
+			devices: newDeviceSnapshot(Devices{
+				"GPU-0": &Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+			}),
+		},
+		nvml: &fabricTestNvml{
+			devices: map[string]*fabricTestDevice{"GPU-0": device},
+		},
+	}
+
+	notReady := r.markFabricNotReady()
+	require.True(t, notReady["GPU-0"])
+
+	start := time.Now()
+	checker := newFabricChecker(start, time.Minute)
+	checker.add("GPU-0", device, r.Devices()["GPU-0"])
+
+	// An XID error is reported before the fabric becomes ready.
+	r.SetDeviceHealth("GPU-0", pluginapi.Unhealthy, HealthReasonXID)
+
+	health := make(chan *Device, 1)
+	checker.check(start, health)
+	close(health)
+	for d := range health {
+		r.SetDeviceHealth(d.ID, d.Health, d.HealthReason)
+	}
+
+	require.Empty(t, checker.gpus)
+	require.Equal(t, pluginapi.Unhealthy, r.Devices()["GPU-0"].Health)
+}
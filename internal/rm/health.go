@@ -21,9 +21,11 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 const (
@@ -40,10 +42,14 @@ const (
 	envEnableHealthChecks = "DP_ENABLE_HEALTHCHECKS"
 )
 
-// CheckHealth performs health checks on a set of devices, writing to the 'unhealthy' channel with any unhealthy devices
-func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devices, unhealthy chan<- *Device) error {
+// CheckHealth performs health checks on a set of devices, writing to the 'health' channel with any devices whose health changes
+func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devices, health chan<- *Device) error {
 	xids := getDisabledHealthCheckXids()
-	if xids.IsAllDisabled() {
+	throttle := r.newThrottleChecker()
+	fabric := newFabricChecker(time.Now(), r.fabricReadyTimeout())
+	// Devices on GPUs whose fabric is not ready are only marked as healthy by
+	// the health checks, so these cannot be skipped in this case.
+	if xids.IsAllDisabled() && !throttle.enabled() && len(r.fabricNotReady) == 0 {
 		return nil
 	}
 
@@ -80,7 +86,7 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		uuid, gi, ci, err := r.getDevicePlacement(d)
 		if err != nil {
 			klog.Warningf("Could not determine device placement for %v: %v; Marking it unhealthy.", d.ID, err)
-			unhealthy <- d
+			health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 			continue
 		}
 		deviceIDToGiMap[d.ID] = gi
@@ -90,14 +96,18 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		gpu, ret := r.nvml.DeviceGetHandleByUUID(uuid)
 		if ret != nvml.SUCCESS {
 			klog.Infof("unable to get device handle from UUID: %v; marking it as unhealthy", ret)
-			unhealthy <- d
+			health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 			continue
 		}
+		throttle.add(uuid, gpu, d)
+		if r.fabricNotReady[uuid] {
+			fabric.add(uuid, gpu, d)
+		}
 
 		supportedEvents, ret := gpu.GetSupportedEventTypes()
 		if ret != nvml.SUCCESS {
 			klog.Infof("unable to determine the supported events for %v: %v; marking it as unhealthy", d.ID, ret)
-			unhealthy <- d
+			health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 			continue
 		}
 
@@ -107,7 +117,7 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 			klog.Warningf("Device %v is too old to support healthchecking.", d.ID)
 		case ret != nvml.SUCCESS:
 			klog.Infof("Marking device %v as unhealthy: %v", d.ID, ret)
-			unhealthy <- d
+			health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 		}
 	}
 
@@ -118,6 +128,9 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		default:
 		}
 
+		throttle.check(time.Now(), health)
+		fabric.check(time.Now(), health)
+
 		e, ret := eventSet.Wait(5000)
 		if ret == nvml.ERROR_TIMEOUT {
 			continue
@@ -125,7 +138,7 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		if ret != nvml.SUCCESS {
 			klog.Infof("Error waiting for event: %v; Marking all devices as unhealthy", ret)
 			for _, d := range devices {
-				unhealthy <- d
+				health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 			}
 			continue
 		}
@@ -146,7 +159,7 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 			// If we cannot reliably determine the device UUID, we mark all devices as unhealthy.
 			klog.Infof("Failed to determine uuid for event %v: %v; Marking all devices as unhealthy.", e, ret)
 			for _, d := range devices {
-				unhealthy <- d
+				health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 			}
 			continue
 		}
@@ -167,7 +180,7 @@ func (r *nvmlResourceManager) checkHealth(stop <-chan interface{}, devices Devic
 		}
 
 		klog.Infof("XidCriticalError: Xid=%d on Device=%s; marking device as unhealthy.", e.EventData, d.ID)
-		unhealthy <- d
+		health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonXID)
 	}
 }
 
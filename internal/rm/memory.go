@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// freeMemoryPollInterval defines how often the free memory of a GPU is
+// queried while waiting for it to become available.
+const freeMemoryPollInterval = 500 * time.Millisecond
+
+// memoryShare records how many of the replicas of a GPU are requested.
+type memoryShare struct {
+	requested int
+	replicas  int
+}
+
+// required returns the amount of memory that must be free on a GPU for the
+// share to be available. Memory reserved by the driver is never free and is
+// excluded.
+func (s memoryShare) required(info nvml.Memory_v2) uint64 {
+	return (info.Total - info.Reserved) * uint64(s.requested) / uint64(s.replicas)
+}
+
+// WaitForFreeMemory waits until the share of GPU memory corresponding to the
+// specified devices is free, or the context is done. Only replicated devices
+// are considered since full GPUs and MIG devices are never shared with other
+// containers.
+func (r *nvmlResourceManager) WaitForFreeMemory(ctx context.Context, ids []string) error {
+	return r.waitForFreeMemory(ctx, ids, freeMemoryPollInterval)
+}
+
+func (r *nvmlResourceManager) waitForFreeMemory(ctx context.Context, ids []string, interval time.Duration) error {
+	shares := getMemoryShares(r.Devices().Subset(ids))
+	if len(shares) == 0 {
+		return nil
+	}
+
+	ret := r.nvml.Init()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		ret := r.nvml.Shutdown()
+		if ret != nvml.SUCCESS {
+			klog.Infof("Error shutting down NVML: %v", ret)
+		}
+	}()
+
+	var waiting bool
+	for {
+		pending, err := r.getPendingMemory(shares)
+		if err != nil {
+			// Since the wait only smooths over the turnover of containers
+			// sharing a GPU, the allocation is not failed if the free memory
+			// cannot be determined.
+			klog.Warningf("Unable to determine free memory for %v: %v; not waiting.", ids, err)
+			return nil
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if !waiting {
+			klog.Infof("Waiting for free memory on GPUs: %v", strings.Join(pending, "; "))
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for free memory on GPUs: %v", strings.Join(pending, "; "))
+		case <-time.After(interval):
+		}
+	}
+}
+
+// getPendingMemory returns a description of each GPU on which less memory is
+// free than is required for the requested share.
+func (r *nvmlResourceManager) getPendingMemory(shares map[string]memoryShare) ([]string, error) {
+	var pending []string
+	for uuid, share := range shares {
+		gpu, ret := r.nvml.DeviceGetHandleByUUID(uuid)
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("unable to get device handle from UUID %v: %v", uuid, ret)
+		}
+		info, ret := gpu.GetMemoryInfo_v2()
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to get memory info for %v: %v", uuid, ret)
+		}
+		if required := share.required(info); info.Free < required {
+			pending = append(pending, fmt.Sprintf("%v (%d MiB required, %d MiB free)", uuid, required/(1024*1024), info.Free/(1024*1024)))
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// getMemoryShares returns the share of each GPU that is requested by the
+// specified replicated devices.
+func getMemoryShares(devices Devices) map[string]memoryShare {
+	shares := make(map[string]memoryShare)
+	for _, d := range devices {
+		if d.IsMigDevice() || d.Replicas <= 1 {
+			continue
+		}
+		share := shares[d.GetUUID()]
+		share.requested++
+		share.replicas = d.Replicas
+		shares[d.GetUUID()] = share
+	}
+	return shares
+}
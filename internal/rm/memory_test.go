@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+type memoryTestNvml struct {
+	nvml.Interface
+	devices map[string]*memoryTestDevice
+}
+
+func (n *memoryTestNvml) Init() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (n *memoryTestNvml) Shutdown() nvml.Return {
+	return nvml.SUCCESS
+}
+
+func (n *memoryTestNvml) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return) {
+	d, ok := n.devices[uuid]
+	if !ok {
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+	return d, nvml.SUCCESS
+}
+
+// memoryTestDevice returns the free memory values in order, repeating the
+// last one.
+type memoryTestDevice struct {
+	nvml.Device
+	ret  nvml.Return
+	free []uint64
+}
+
+func (d *memoryTestDevice) GetMemoryInfo_v2() (nvml.Memory_v2, nvml.Return) {
+	free := d.free[0]
+	if len(d.free) > 1 {
+		d.free = d.free[1:]
+	}
+	return nvml.Memory_v2{Total: 1100, Reserved: 100, Free: free}, d.ret
+}
+
+func TestWaitForFreeMemory(t *testing.T) {
+	testCases := []struct {
+		description   string
+		ids           []string
+		device        *memoryTestDevice
+		timeout       time.Duration
+		expectedError bool
+	}{
+		{
+			description: "memory is free",
+			ids:         []string{"GPU-0::0", "GPU-0::1"},
+			device:      &memoryTestDevice{free: []uint64{500}},
+			timeout:     time.Minute,
+		},
+		{
+			description: "memory becomes free",
+			ids:         []string{"GPU-0::0", "GPU-0::1"},
+			device:      &memoryTestDevice{free: []uint64{100, 300, 500}},
+			timeout:     time.Minute,
+		},
+		{
+			description:   "memory never becomes free",
+			ids:           []string{"GPU-0::0", "GPU-0::1"},
+			device:        &memoryTestDevice{free: []uint64{499}},
+			timeout:       10 * time.Millisecond,
+			expectedError: true,
+		},
+		{
+			description: "non-replicated devices are ignored",
+			ids:         []string{"GPU-1"},
+			device:      &memoryTestDevice{free: []uint64{0}},
+		},
+		{
+			description: "memory info not supported",
+			ids:         []string{"GPU-0::0"},
+			device:      &memoryTestDevice{ret: nvml.ERROR_NOT_SUPPORTED, free: []uint64{0}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices := Devices{
+				"GPU-0::0": &Device{Device: pluginapi.Device{ID: "GPU-0::0"}, Replicas: 4},
+				"GPU-0::1": &Device{Device: pluginapi.Device{ID: "GPU-0::1"}, Replicas: 4},
+				"GPU-0::2": &Device{Device: pluginapi.Device{ID: "GPU-0::2"}, Replicas: 4},
+				"GPU-0::3": &Device{Device: pluginapi.Device{ID: "GPU-0::3"}, Replicas: 4},
+				"GPU-1":    &Device{Device: pluginapi.Device{ID: "GPU-1"}},
+			}
+			r := &nvmlResourceManager{
+				resourceManager: resourceManager{
+					devices: newDeviceSnapshot(devices),
+				},
+				nvml: &memoryTestNvml{
+					devices: map[string]*memoryTestDevice{"GPU-0": tc.device, "GPU-1": tc.device},
+				},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			err := r.waitForFreeMemory(ctx, tc.ids, time.Millisecond)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// GetNCCLEnvs returns the NCCL environment variables that describe the link
+// topology between the specified devices. Envvars are only returned if the
+// devices span more than one full GPU.
+func (r *nvmlResourceManager) GetNCCLEnvs(ids []string) (map[string]string, error) {
+	devices := r.Devices().Subset(ids)
+	for _, d := range devices {
+		if d.IsMigDevice() {
+			return nil, nil
+		}
+	}
+
+	uuids := devices.GetUUIDs()
+	if len(uuids) < 2 {
+		return nil, nil
+	}
+	sort.Strings(uuids)
+
+	ret := r.nvml.Init()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %v", ret)
+	}
+	defer func() {
+		ret := r.nvml.Shutdown()
+		if ret != nvml.SUCCESS {
+			klog.Infof("Error shutting down NVML: %v", ret)
+		}
+	}()
+
+	var gpus []nvml.Device
+	for _, uuid := range uuids {
+		gpu, ret := r.nvml.DeviceGetHandleByUUID(uuid)
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("unable to get device handle from UUID %v: %v", uuid, ret)
+		}
+		gpus = append(gpus, gpu)
+	}
+
+	return getNCCLEnvsForLinks(gpus)
+}
+
+// getNCCLEnvsForLinks determines the NCCL envvars for the links between each
+// pair of the specified GPUs.
+//
+// If P2P is not supported between any pair of GPUs, NCCL_IGNORE_DISABLED_P2P
+// is set so that NCCL falls back to shared memory transfers for that pair.
+// NCCL_P2P_LEVEL is set to the widest PCI topology level across which P2P is
+// supported by the allocated GPUs.
+func getNCCLEnvsForLinks(gpus []nvml.Device) (map[string]string, error) {
+	var p2pSupported bool
+	var p2pDisabled bool
+	level := nvml.TOPOLOGY_INTERNAL
+	for i := range gpus {
+		for j := i + 1; j < len(gpus); j++ {
+			status, ret := gpus[i].GetP2PStatus(gpus[j], nvml.P2P_CAPS_INDEX_READ)
+			if ret != nvml.SUCCESS {
+				return nil, fmt.Errorf("failed to get P2P status: %v", ret)
+			}
+			if status != nvml.P2P_STATUS_OK {
+				p2pDisabled = true
+				continue
+			}
+			p2pSupported = true
+
+			ancestor, ret := gpus[i].GetTopologyCommonAncestor(gpus[j])
+			if ret != nvml.SUCCESS {
+				return nil, fmt.Errorf("failed to get topology common ancestor: %v", ret)
+			}
+			if ancestor > level {
+				level = ancestor
+			}
+		}
+	}
+
+	envs := make(map[string]string)
+	if p2pDisabled {
+		envs["NCCL_IGNORE_DISABLED_P2P"] = "1"
+	}
+	if p2pSupported {
+		envs["NCCL_P2P_LEVEL"] = ncclP2PLevel(level)
+	}
+	return envs, nil
+}
+
+// ncclP2PLevel maps an NVML topology level to the corresponding NCCL P2P level.
+func ncclP2PLevel(level nvml.GpuTopologyLevel) string {
+	switch {
+	case level <= nvml.TOPOLOGY_SINGLE:
+		return "PIX"
+	case level <= nvml.TOPOLOGY_MULTIPLE:
+		return "PXB"
+	case level <= nvml.TOPOLOGY_NODE:
+		return "PHB"
+	default:
+		return "SYS"
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+// linkTestDevice is an nvml.Device with the links to other devices defined
+// by the owning linkTestTopology.
+type linkTestDevice struct {
+	nvml.Device
+	index    int
+	topology *linkTestTopology
+}
+
+type linkTestTopology struct {
+	// levels stores the common ancestor for each pair of devices. A negative
+	// level indicates that P2P is not supported between the devices.
+	levels map[[2]int]nvml.GpuTopologyLevel
+}
+
+func (t *linkTestTopology) devices(n int) []nvml.Device {
+	var devices []nvml.Device
+	for i := 0; i < n; i++ {
+		devices = append(devices, &linkTestDevice{index: i, topology: t})
+	}
+	return devices
+}
+
+func (d *linkTestDevice) level(other nvml.Device) nvml.GpuTopologyLevel {
+	return d.topology.levels[[2]int{d.index, other.(*linkTestDevice).index}]
+}
+
+func (d *linkTestDevice) GetP2PStatus(other nvml.Device, _ nvml.GpuP2PCapsIndex) (nvml.GpuP2PStatus, nvml.Return) {
+	if d.level(other) < 0 {
+		return nvml.P2P_STATUS_NOT_SUPPORTED, nvml.SUCCESS
+	}
+	return nvml.P2P_STATUS_OK, nvml.SUCCESS
+}
+
+func (d *linkTestDevice) GetTopologyCommonAncestor(other nvml.Device) (nvml.GpuTopologyLevel, nvml.Return) {
+	return d.level(other), nvml.SUCCESS
+}
+
+func TestGetNCCLEnvsForLinks(t *testing.T) {
+	testCases := []struct {
+		description  string
+		levels       map[[2]int]nvml.GpuTopologyLevel
+		expectedEnvs map[string]string
+	}{
+		{
+			description: "same PCIe switch",
+			levels: map[[2]int]nvml.GpuTopologyLevel{
+				{0, 1}: nvml.TOPOLOGY_SINGLE,
+			},
+			expectedEnvs: map[string]string{"NCCL_P2P_LEVEL": "PIX"},
+		},
+		{
+			description: "widest level is used",
+			levels: map[[2]int]nvml.GpuTopologyLevel{
+				{0, 1}: nvml.TOPOLOGY_SINGLE,
+				{0, 2}: nvml.TOPOLOGY_SYSTEM,
+				{1, 2}: nvml.TOPOLOGY_NODE,
+			},
+			expectedEnvs: map[string]string{"NCCL_P2P_LEVEL": "SYS"},
+		},
+		{
+			description: "P2P partially disabled",
+			levels: map[[2]int]nvml.GpuTopologyLevel{
+				{0, 1}: nvml.TOPOLOGY_MULTIPLE,
+				{0, 2}: -1,
+				{1, 2}: -1,
+			},
+			expectedEnvs: map[string]string{
+				"NCCL_IGNORE_DISABLED_P2P": "1",
+				"NCCL_P2P_LEVEL":           "PXB",
+			},
+		},
+		{
+			description: "P2P disabled",
+			levels: map[[2]int]nvml.GpuTopologyLevel{
+				{0, 1}: -1,
+			},
+			expectedEnvs: map[string]string{"NCCL_IGNORE_DISABLED_P2P": "1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			topology := &linkTestTopology{levels: tc.levels}
+			n := 0
+			for pair := range tc.levels {
+				n = max(n, pair[1]+1)
+			}
+
+			envs, err := getNCCLEnvsForLinks(topology.devices(n))
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedEnvs, envs)
+		})
+	}
+}
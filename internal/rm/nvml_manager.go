@@ -18,6 +18,7 @@ package rm
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/NVIDIA/go-gpuallocator/gpuallocator"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
@@ -31,6 +32,9 @@ import (
 type nvmlResourceManager struct {
 	resourceManager
 	nvml nvml.Interface
+	// fabricNotReady stores the UUIDs of the GPUs whose fabric was not ready
+	// when the resource manager was created.
+	fabricNotReady map[string]bool
 }
 
 var _ ResourceManager = (*nvmlResourceManager)(nil)
@@ -62,10 +66,11 @@ func NewNVMLResourceManagers(infolib info.Interface, nvmllib nvml.Interface, dev
 			resourceManager: resourceManager{
 				config:   config,
 				resource: resourceName,
-				devices:  devices,
+				devices:  newDeviceSnapshot(devices),
 			},
 			nvml: nvmllib,
 		}
+		r.fabricNotReady = r.markFabricNotReady()
 		rms = append(rms, r)
 	}
 
@@ -90,22 +95,28 @@ func (r *nvmlResourceManager) GetDevicePaths(ids []string) []string {
 	return append(paths, r.Devices().Subset(ids).GetPaths()...)
 }
 
-// CheckHealth performs health checks on a set of devices, writing to the 'unhealthy' channel with any unhealthy devices
-func (r *nvmlResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
-	return r.checkHealth(stop, r.devices, unhealthy)
+// CheckHealth performs health checks on a set of devices, writing to the 'health' channel with any devices whose health changes
+func (r *nvmlResourceManager) CheckHealth(stop <-chan interface{}, health chan<- *Device) error {
+	return r.checkHealth(stop, r.Devices(), health)
 }
 
 // getPreferredAllocation runs an allocation algorithm over the inputs.
 // The algorithm chosen is based both on the incoming set of available devices and various config settings.
 func (r *nvmlResourceManager) getPreferredAllocation(available, required []string, size int) ([]string, error) {
-	// If all of the available devices are full GPUs without replicas, then
-	// calculate an aligned allocation across those devices.
-	if r.Devices().AlignedAllocationSupported() && !AnnotatedIDs(available).AnyHasAnnotations() {
-		return r.alignedAlloc(available, required, size)
+	// The kubelet does not guarantee the order of the available devices. We
+	// sort them so that the same allocation is returned for the same set of
+	// devices.
+	available = slices.Sorted(slices.Values(available))
+
+	// By default, an aligned allocation is calculated if all of the available
+	// devices are full GPUs without replicas. Otherwise, the devices are
+	// distributed evenly across all replicated GPUs.
+	devices, err := allocateWithPolicies(r.allocationPolicies(), available, required, size)
+	if err != nil {
+		return nil, err
 	}
-
-	// Otherwise, distribute them evenly across all replicated GPUs
-	return r.distributedAlloc(available, required, size)
+	compareWithCanary(r.resource, r.canaryAllocationPolicy(), devices, available, required, size)
+	return devices, nil
 }
 
 // alignedAlloc shells out to the alignedAllocationPolicy that is set in
@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/klog/v2"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// defaultAllocationPolicies is the chain of policies that is used if no
+// allocation policies are configured.
+var defaultAllocationPolicies = []string{
+	spec.AllocationPolicyAligned,
+	spec.AllocationPolicyDistributed,
+}
+
+// allocationPolicy calculates a preferred allocation.
+type allocationPolicy struct {
+	name string
+	// applies checks whether the policy can be used for the specified
+	// available devices.
+	applies func(available []string) bool
+	// allocate returns the preferred allocation.
+	allocate func(available, required []string, size int) ([]string, error)
+}
+
+// allocationPolicies returns the chain of allocation policies configured for
+// the resource manager.
+func (r *nvmlResourceManager) allocationPolicies() []allocationPolicy {
+	names := defaultAllocationPolicies
+	if r.config.Flags.Plugin != nil && r.config.Flags.Plugin.AllocationPolicies != nil && len(*r.config.Flags.Plugin.AllocationPolicies) > 0 {
+		names = *r.config.Flags.Plugin.AllocationPolicies
+	}
+
+	var policies []allocationPolicy
+	for _, name := range names {
+		policy, ok := r.allocationPolicy(name)
+		if !ok {
+			klog.Warningf("Ignoring unknown allocation policy %v", name)
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// canaryAllocationPolicy returns the canary allocation policy configured for
+// the resource manager, or nil if no canary policy is configured.
+func (r *nvmlResourceManager) canaryAllocationPolicy() *allocationPolicy {
+	if r.config.Flags.Plugin == nil || r.config.Flags.Plugin.CanaryAllocationPolicy == nil || *r.config.Flags.Plugin.CanaryAllocationPolicy == "" {
+		return nil
+	}
+	name := *r.config.Flags.Plugin.CanaryAllocationPolicy
+	policy, ok := r.allocationPolicy(name)
+	if !ok {
+		klog.Warningf("Ignoring unknown canary allocation policy %v", name)
+		return nil
+	}
+	return &policy
+}
+
+// allocationPolicy returns the allocation policy with the specified name.
+func (r *nvmlResourceManager) allocationPolicy(name string) (allocationPolicy, bool) {
+	switch name {
+	case spec.AllocationPolicyAligned:
+		// An aligned allocation is only possible if all of the available
+		// devices are full GPUs without replicas.
+		return allocationPolicy{
+			name: name,
+			applies: func(available []string) bool {
+				return r.Devices().AlignedAllocationSupported() && !AnnotatedIDs(available).AnyHasAnnotations()
+			},
+			allocate: r.alignedAlloc,
+		}, true
+	case spec.AllocationPolicyDistributed:
+		return allocationPolicy{
+			name:     name,
+			applies:  func([]string) bool { return true },
+			allocate: r.distributedAlloc,
+		}, true
+	case spec.AllocationPolicyFirstFit:
+		return allocationPolicy{
+			name:     name,
+			applies:  func([]string) bool { return true },
+			allocate: firstFitAlloc,
+		}, true
+	}
+	return allocationPolicy{}, false
+}
+
+// allocateWithPolicies returns the allocation of the first policy in the
+// chain that applies to the available devices and succeeds.
+func allocateWithPolicies(policies []allocationPolicy, available, required []string, size int) ([]string, error) {
+	var errs error
+	for _, policy := range policies {
+		if !policy.applies(available) {
+			klog.V(4).Infof("Allocation policy %v does not apply to %v", policy.name, available)
+			continue
+		}
+		devices, err := policy.allocate(available, required, size)
+		if err != nil {
+			klog.Warningf("Allocation policy %v failed; trying next policy: %v", policy.name, err)
+			errs = errors.Join(errs, fmt.Errorf("%v: %w", policy.name, err))
+			continue
+		}
+		return devices, nil
+	}
+	if errs == nil {
+		return nil, fmt.Errorf("no allocation policy applies to the available devices")
+	}
+	return nil, errs
+}
+
+// firstFitAlloc returns the required devices followed by the first available
+// devices in order until the requested size is reached.
+func firstFitAlloc(available, required []string, size int) ([]string, error) {
+	devices := slices.Clone(required)
+	for _, id := range available {
+		if len(devices) >= size {
+			break
+		}
+		if slices.Contains(required, id) {
+			continue
+		}
+		devices = append(devices, id)
+	}
+	if len(devices) < size {
+		return nil, fmt.Errorf("not enough available devices to satisfy allocation")
+	}
+	return devices, nil
+}
+
+// compareWithCanary computes the allocation of the canary policy and logs it
+// if it differs from the preferred allocation. The order of the devices is
+// ignored. It returns whether the allocations differ.
+func compareWithCanary(resource spec.ResourceName, canary *allocationPolicy, preferred, available, required []string, size int) bool {
+	if canary == nil {
+		return false
+	}
+	if !canary.applies(available) {
+		klog.V(4).Infof("Canary allocation policy %v does not apply to %v", canary.name, available)
+		return false
+	}
+	devices, err := canary.allocate(available, required, size)
+	if err != nil {
+		klog.Warningf("Canary allocation policy %v failed for '%s': %v", canary.name, resource, err)
+		return true
+	}
+	if slices.Equal(slices.Sorted(slices.Values(devices)), slices.Sorted(slices.Values(preferred))) {
+		return false
+	}
+	klog.InfoS("Canary allocation differs from preferred allocation", "resource", resource, "policy", canary.name, "preferred", preferred, "canary", devices)
+	return true
+}
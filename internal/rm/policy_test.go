@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateWithPolicies(t *testing.T) {
+	always := func([]string) bool { return true }
+	never := func([]string) bool { return false }
+	fails := func([]string, []string, int) ([]string, error) {
+		return nil, fmt.Errorf("failed")
+	}
+	returns := func(devices ...string) func([]string, []string, int) ([]string, error) {
+		return func([]string, []string, int) ([]string, error) {
+			return devices, nil
+		}
+	}
+
+	testCases := []struct {
+		description     string
+		policies        []allocationPolicy
+		expectedDevices []string
+		expectedError   bool
+	}{
+		{
+			description: "first applicable policy is used",
+			policies: []allocationPolicy{
+				{name: "a", applies: always, allocate: returns("GPU-0")},
+				{name: "b", applies: always, allocate: returns("GPU-1")},
+			},
+			expectedDevices: []string{"GPU-0"},
+		},
+		{
+			description: "policies that do not apply are skipped",
+			policies: []allocationPolicy{
+				{name: "a", applies: never, allocate: returns("GPU-0")},
+				{name: "b", applies: always, allocate: returns("GPU-1")},
+			},
+			expectedDevices: []string{"GPU-1"},
+		},
+		{
+			description: "failed policies fall back to the next",
+			policies: []allocationPolicy{
+				{name: "a", applies: always, allocate: fails},
+				{name: "b", applies: always, allocate: returns("GPU-1")},
+			},
+			expectedDevices: []string{"GPU-1"},
+		},
+		{
+			description: "all policies fail",
+			policies: []allocationPolicy{
+				{name: "a", applies: always, allocate: fails},
+				{name: "b", applies: always, allocate: fails},
+			},
+			expectedError: true,
+		},
+		{
+			description: "no policy applies",
+			policies: []allocationPolicy{
+				{name: "a", applies: never, allocate: returns("GPU-0")},
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices, err := allocateWithPolicies(tc.policies, nil, nil, 1)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDevices, devices)
+		})
+	}
+}
+
+func TestFirstFitAlloc(t *testing.T) {
+	testCases := []struct {
+		description     string
+		available       []string
+		required        []string
+		size            int
+		expectedDevices []string
+		expectedError   bool
+	}{
+		{
+			description:     "first available devices are used",
+			available:       []string{"GPU-0", "GPU-1", "GPU-2"},
+			size:            2,
+			expectedDevices: []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description:     "required devices are included first",
+			available:       []string{"GPU-0", "GPU-1", "GPU-2"},
+			required:        []string{"GPU-2"},
+			size:            2,
+			expectedDevices: []string{"GPU-2", "GPU-0"},
+		},
+		{
+			description:   "not enough devices",
+			available:     []string{"GPU-0"},
+			size:          2,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			devices, err := firstFitAlloc(tc.available, tc.required, tc.size)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedDevices, devices)
+		})
+	}
+}
+
+func TestCompareWithCanary(t *testing.T) {
+	always := func([]string) bool { return true }
+	never := func([]string) bool { return false }
+	returns := func(devices ...string) func([]string, []string, int) ([]string, error) {
+		return func([]string, []string, int) ([]string, error) {
+			return devices, nil
+		}
+	}
+
+	testCases := []struct {
+		description    string
+		canary         *allocationPolicy
+		preferred      []string
+		expectedDiffer bool
+	}{
+		{
+			description: "no canary",
+			preferred:   []string{"GPU-0"},
+		},
+		{
+			description: "canary does not apply",
+			canary:      &allocationPolicy{name: "canary", applies: never, allocate: returns("GPU-1")},
+			preferred:   []string{"GPU-0"},
+		},
+		{
+			description: "same devices in a different order",
+			canary:      &allocationPolicy{name: "canary", applies: always, allocate: returns("GPU-1", "GPU-0")},
+			preferred:   []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description:    "different devices",
+			canary:         &allocationPolicy{name: "canary", applies: always, allocate: returns("GPU-1")},
+			preferred:      []string{"GPU-0"},
+			expectedDiffer: true,
+		},
+		{
+			description: "canary fails",
+			canary: &allocationPolicy{name: "canary", applies: always, allocate: func([]string, []string, int) ([]string, error) {
+				return nil, fmt.Errorf("failed")
+			}},
+			preferred:      []string{"GPU-0"},
+			expectedDiffer: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			differ := compareWithCanary("nvidia.com/gpu", tc.canary, tc.preferred, []string{"GPU-0", "GPU-1"}, nil, len(tc.preferred))
+			require.Equal(t, tc.expectedDiffer, differ)
+		})
+	}
+}
@@ -17,6 +17,7 @@
 package rm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -33,7 +34,7 @@ import (
 type resourceManager struct {
 	config   *spec.Config
 	resource spec.ResourceName
-	devices  Devices
+	devices  *deviceSnapshot
 }
 
 // ResourceManager provides an interface for listing a set of Devices and checking health on them
@@ -42,9 +43,13 @@ type resourceManager struct {
 type ResourceManager interface {
 	Resource() spec.ResourceName
 	Devices() Devices
+	SetDeviceHealth(id string, health string, reason HealthReason) string
+	AdoptCheckpointedIDs(ids []string)
 	GetDevicePaths([]string) []string
+	GetNCCLEnvs([]string) (map[string]string, error)
+	WaitForFreeMemory(ctx context.Context, ids []string) error
 	GetPreferredAllocation(available, required []string, size int) ([]string, error)
-	CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error
+	CheckHealth(stop <-chan interface{}, health chan<- *Device) error
 	ValidateRequest(AnnotatedIDs) error
 }
 
@@ -53,9 +58,36 @@ func (r *resourceManager) Resource() spec.ResourceName {
 	return r.resource
 }
 
-// Devices gets the devices managed by the ResourceManager
+// Devices gets a snapshot of the devices managed by the ResourceManager.
+// The returned devices must not be modified.
 func (r *resourceManager) Devices() Devices {
-	return r.devices
+	return r.devices.Load()
+}
+
+// SetDeviceHealth records the health of the device with the specified ID as
+// reported by the specified source and returns the resulting health of the
+// device. A device is unhealthy as long as any source reports it as unhealthy.
+func (r *resourceManager) SetDeviceHealth(id string, health string, reason HealthReason) string {
+	result := health
+	r.devices.update(func(devices Devices) {
+		d, exists := devices[id]
+		if !exists {
+			return
+		}
+		updated := d.clone()
+		updated.setHealth(health, reason)
+		devices[id] = updated
+		result = updated.Health
+	})
+	return result
+}
+
+// AdoptCheckpointedIDs ensures that the specified device IDs, which the
+// kubelet has checkpointed as allocated, remain known to the ResourceManager.
+func (r *resourceManager) AdoptCheckpointedIDs(ids []string) {
+	r.devices.update(func(devices Devices) {
+		devices.adoptCheckpointedIDs(ids)
+	})
 }
 
 var errInvalidRequest = errors.New("invalid request")
@@ -66,7 +98,7 @@ var errInvalidRequest = errors.New("invalid request")
 func (r *resourceManager) ValidateRequest(ids AnnotatedIDs) error {
 	// Assert that all requested IDs are known to the resource manager
 	for _, id := range ids {
-		if !r.devices.Contains(id) {
+		if !r.Devices().Contains(id) {
 			return fmt.Errorf("%w: unknown device: %s", errInvalidRequest, id)
 		}
 	}
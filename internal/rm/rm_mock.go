@@ -4,6 +4,7 @@
 package rm
 
 import (
+	"context"
 	"sync"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
@@ -19,7 +20,10 @@ var _ ResourceManager = &ResourceManagerMock{}
 //
 //		// make and configure a mocked ResourceManager
 //		mockedResourceManager := &ResourceManagerMock{
-//			CheckHealthFunc: func(stop <-chan interface{}, unhealthy chan<- *Device) error {
+//			AdoptCheckpointedIDsFunc: func(ids []string) {
+//				panic("mock out the AdoptCheckpointedIDs method")
+//			},
+//			CheckHealthFunc: func(stop <-chan interface{}, health chan<- *Device) error {
 //				panic("mock out the CheckHealth method")
 //			},
 //			DevicesFunc: func() Devices {
@@ -28,15 +32,24 @@ var _ ResourceManager = &ResourceManagerMock{}
 //			GetDevicePathsFunc: func(strings []string) []string {
 //				panic("mock out the GetDevicePaths method")
 //			},
+//			GetNCCLEnvsFunc: func(strings []string) (map[string]string, error) {
+//				panic("mock out the GetNCCLEnvs method")
+//			},
 //			GetPreferredAllocationFunc: func(available []string, required []string, size int) ([]string, error) {
 //				panic("mock out the GetPreferredAllocation method")
 //			},
 //			ResourceFunc: func() spec.ResourceName {
 //				panic("mock out the Resource method")
 //			},
+//			SetDeviceHealthFunc: func(id string, health string, reason HealthReason) string {
+//				panic("mock out the SetDeviceHealth method")
+//			},
 //			ValidateRequestFunc: func(annotatedIDs AnnotatedIDs) error {
 //				panic("mock out the ValidateRequest method")
 //			},
+//			WaitForFreeMemoryFunc: func(ctx context.Context, ids []string) error {
+//				panic("mock out the WaitForFreeMemory method")
+//			},
 //		}
 //
 //		// use mockedResourceManager in code that requires ResourceManager
@@ -44,8 +57,11 @@ var _ ResourceManager = &ResourceManagerMock{}
 //
 //	}
 type ResourceManagerMock struct {
+	// AdoptCheckpointedIDsFunc mocks the AdoptCheckpointedIDs method.
+	AdoptCheckpointedIDsFunc func(ids []string)
+
 	// CheckHealthFunc mocks the CheckHealth method.
-	CheckHealthFunc func(stop <-chan interface{}, unhealthy chan<- *Device) error
+	CheckHealthFunc func(stop <-chan interface{}, health chan<- *Device) error
 
 	// DevicesFunc mocks the Devices method.
 	DevicesFunc func() Devices
@@ -53,23 +69,37 @@ type ResourceManagerMock struct {
 	// GetDevicePathsFunc mocks the GetDevicePaths method.
 	GetDevicePathsFunc func(strings []string) []string
 
+	// GetNCCLEnvsFunc mocks the GetNCCLEnvs method.
+	GetNCCLEnvsFunc func(strings []string) (map[string]string, error)
+
 	// GetPreferredAllocationFunc mocks the GetPreferredAllocation method.
 	GetPreferredAllocationFunc func(available []string, required []string, size int) ([]string, error)
 
 	// ResourceFunc mocks the Resource method.
 	ResourceFunc func() spec.ResourceName
 
+	// SetDeviceHealthFunc mocks the SetDeviceHealth method.
+	SetDeviceHealthFunc func(id string, health string, reason HealthReason) string
+
 	// ValidateRequestFunc mocks the ValidateRequest method.
 	ValidateRequestFunc func(annotatedIDs AnnotatedIDs) error
 
+	// WaitForFreeMemoryFunc mocks the WaitForFreeMemory method.
+	WaitForFreeMemoryFunc func(ctx context.Context, ids []string) error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AdoptCheckpointedIDs holds details about calls to the AdoptCheckpointedIDs method.
+		AdoptCheckpointedIDs []struct {
+			// Ids is the ids argument value.
+			Ids []string
+		}
 		// CheckHealth holds details about calls to the CheckHealth method.
 		CheckHealth []struct {
 			// Stop is the stop argument value.
 			Stop <-chan interface{}
-			// Unhealthy is the unhealthy argument value.
-			Unhealthy chan<- *Device
+			// Health is the health argument value.
+			Health chan<- *Device
 		}
 		// Devices holds details about calls to the Devices method.
 		Devices []struct {
@@ -79,6 +109,11 @@ type ResourceManagerMock struct {
 			// Strings is the strings argument value.
 			Strings []string
 		}
+		// GetNCCLEnvs holds details about calls to the GetNCCLEnvs method.
+		GetNCCLEnvs []struct {
+			// Strings is the strings argument value.
+			Strings []string
+		}
 		// GetPreferredAllocation holds details about calls to the GetPreferredAllocation method.
 		GetPreferredAllocation []struct {
 			// Available is the available argument value.
@@ -91,28 +126,80 @@ type ResourceManagerMock struct {
 		// Resource holds details about calls to the Resource method.
 		Resource []struct {
 		}
+		// SetDeviceHealth holds details about calls to the SetDeviceHealth method.
+		SetDeviceHealth []struct {
+			// Id is the id argument value.
+			Id string
+			// Health is the health argument value.
+			Health string
+			// Reason is the reason argument value.
+			Reason HealthReason
+		}
 		// ValidateRequest holds details about calls to the ValidateRequest method.
 		ValidateRequest []struct {
 			// AnnotatedIDs is the annotatedIDs argument value.
 			AnnotatedIDs AnnotatedIDs
 		}
+		// WaitForFreeMemory holds details about calls to the WaitForFreeMemory method.
+		WaitForFreeMemory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ids is the ids argument value.
+			Ids []string
+		}
 	}
+	lockAdoptCheckpointedIDs   sync.RWMutex
 	lockCheckHealth            sync.RWMutex
 	lockDevices                sync.RWMutex
 	lockGetDevicePaths         sync.RWMutex
+	lockGetNCCLEnvs            sync.RWMutex
 	lockGetPreferredAllocation sync.RWMutex
 	lockResource               sync.RWMutex
+	lockSetDeviceHealth        sync.RWMutex
 	lockValidateRequest        sync.RWMutex
+	lockWaitForFreeMemory      sync.RWMutex
+}
+
+// AdoptCheckpointedIDs calls AdoptCheckpointedIDsFunc.
+func (mock *ResourceManagerMock) AdoptCheckpointedIDs(ids []string) {
+	callInfo := struct {
+		Ids []string
+	}{
+		Ids: ids,
+	}
+	mock.lockAdoptCheckpointedIDs.Lock()
+	mock.calls.AdoptCheckpointedIDs = append(mock.calls.AdoptCheckpointedIDs, callInfo)
+	mock.lockAdoptCheckpointedIDs.Unlock()
+	if mock.AdoptCheckpointedIDsFunc == nil {
+		return
+	}
+	mock.AdoptCheckpointedIDsFunc(ids)
+}
+
+// AdoptCheckpointedIDsCalls gets all the calls that were made to AdoptCheckpointedIDs.
+// Check the length with:
+//
+//	len(mockedResourceManager.AdoptCheckpointedIDsCalls())
+func (mock *ResourceManagerMock) AdoptCheckpointedIDsCalls() []struct {
+	Ids []string
+} {
+	var calls []struct {
+		Ids []string
+	}
+	mock.lockAdoptCheckpointedIDs.RLock()
+	calls = mock.calls.AdoptCheckpointedIDs
+	mock.lockAdoptCheckpointedIDs.RUnlock()
+	return calls
 }
 
 // CheckHealth calls CheckHealthFunc.
-func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
+func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, health chan<- *Device) error {
 	callInfo := struct {
-		Stop      <-chan interface{}
-		Unhealthy chan<- *Device
+		Stop   <-chan interface{}
+		Health chan<- *Device
 	}{
-		Stop:      stop,
-		Unhealthy: unhealthy,
+		Stop:   stop,
+		Health: health,
 	}
 	mock.lockCheckHealth.Lock()
 	mock.calls.CheckHealth = append(mock.calls.CheckHealth, callInfo)
@@ -123,7 +210,7 @@ func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy
 		)
 		return errOut
 	}
-	return mock.CheckHealthFunc(stop, unhealthy)
+	return mock.CheckHealthFunc(stop, health)
 }
 
 // CheckHealthCalls gets all the calls that were made to CheckHealth.
@@ -131,12 +218,12 @@ func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy
 //
 //	len(mockedResourceManager.CheckHealthCalls())
 func (mock *ResourceManagerMock) CheckHealthCalls() []struct {
-	Stop      <-chan interface{}
-	Unhealthy chan<- *Device
+	Stop   <-chan interface{}
+	Health chan<- *Device
 } {
 	var calls []struct {
-		Stop      <-chan interface{}
-		Unhealthy chan<- *Device
+		Stop   <-chan interface{}
+		Health chan<- *Device
 	}
 	mock.lockCheckHealth.RLock()
 	calls = mock.calls.CheckHealth
@@ -209,6 +296,42 @@ func (mock *ResourceManagerMock) GetDevicePathsCalls() []struct {
 	return calls
 }
 
+// GetNCCLEnvs calls GetNCCLEnvsFunc.
+func (mock *ResourceManagerMock) GetNCCLEnvs(strings []string) (map[string]string, error) {
+	callInfo := struct {
+		Strings []string
+	}{
+		Strings: strings,
+	}
+	mock.lockGetNCCLEnvs.Lock()
+	mock.calls.GetNCCLEnvs = append(mock.calls.GetNCCLEnvs, callInfo)
+	mock.lockGetNCCLEnvs.Unlock()
+	if mock.GetNCCLEnvsFunc == nil {
+		var (
+			stringToStringOut map[string]string
+			errOut            error
+		)
+		return stringToStringOut, errOut
+	}
+	return mock.GetNCCLEnvsFunc(strings)
+}
+
+// GetNCCLEnvsCalls gets all the calls that were made to GetNCCLEnvs.
+// Check the length with:
+//
+//	len(mockedResourceManager.GetNCCLEnvsCalls())
+func (mock *ResourceManagerMock) GetNCCLEnvsCalls() []struct {
+	Strings []string
+} {
+	var calls []struct {
+		Strings []string
+	}
+	mock.lockGetNCCLEnvs.RLock()
+	calls = mock.calls.GetNCCLEnvs
+	mock.lockGetNCCLEnvs.RUnlock()
+	return calls
+}
+
 // GetPreferredAllocation calls GetPreferredAllocationFunc.
 func (mock *ResourceManagerMock) GetPreferredAllocation(available []string, required []string, size int) ([]string, error) {
 	callInfo := struct {
@@ -283,6 +406,49 @@ func (mock *ResourceManagerMock) ResourceCalls() []struct {
 	return calls
 }
 
+// SetDeviceHealth calls SetDeviceHealthFunc.
+func (mock *ResourceManagerMock) SetDeviceHealth(id string, health string, reason HealthReason) string {
+	callInfo := struct {
+		Id     string
+		Health string
+		Reason HealthReason
+	}{
+		Id:     id,
+		Health: health,
+		Reason: reason,
+	}
+	mock.lockSetDeviceHealth.Lock()
+	mock.calls.SetDeviceHealth = append(mock.calls.SetDeviceHealth, callInfo)
+	mock.lockSetDeviceHealth.Unlock()
+	if mock.SetDeviceHealthFunc == nil {
+		var (
+			sOut string
+		)
+		return sOut
+	}
+	return mock.SetDeviceHealthFunc(id, health, reason)
+}
+
+// SetDeviceHealthCalls gets all the calls that were made to SetDeviceHealth.
+// Check the length with:
+//
+//	len(mockedResourceManager.SetDeviceHealthCalls())
+func (mock *ResourceManagerMock) SetDeviceHealthCalls() []struct {
+	Id     string
+	Health string
+	Reason HealthReason
+} {
+	var calls []struct {
+		Id     string
+		Health string
+		Reason HealthReason
+	}
+	mock.lockSetDeviceHealth.RLock()
+	calls = mock.calls.SetDeviceHealth
+	mock.lockSetDeviceHealth.RUnlock()
+	return calls
+}
+
 // ValidateRequest calls ValidateRequestFunc.
 func (mock *ResourceManagerMock) ValidateRequest(annotatedIDs AnnotatedIDs) error {
 	callInfo := struct {
@@ -317,3 +483,42 @@ func (mock *ResourceManagerMock) ValidateRequestCalls() []struct {
 	mock.lockValidateRequest.RUnlock()
 	return calls
 }
+
+// WaitForFreeMemory calls WaitForFreeMemoryFunc.
+func (mock *ResourceManagerMock) WaitForFreeMemory(ctx context.Context, ids []string) error {
+	callInfo := struct {
+		Ctx context.Context
+		Ids []string
+	}{
+		Ctx: ctx,
+		Ids: ids,
+	}
+	mock.lockWaitForFreeMemory.Lock()
+	mock.calls.WaitForFreeMemory = append(mock.calls.WaitForFreeMemory, callInfo)
+	mock.lockWaitForFreeMemory.Unlock()
+	if mock.WaitForFreeMemoryFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.WaitForFreeMemoryFunc(ctx, ids)
+}
+
+// WaitForFreeMemoryCalls gets all the calls that were made to WaitForFreeMemory.
+// Check the length with:
+//
+//	len(mockedResourceManager.WaitForFreeMemoryCalls())
+func (mock *ResourceManagerMock) WaitForFreeMemoryCalls() []struct {
+	Ctx context.Context
+	Ids []string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Ids []string
+	}
+	mock.lockWaitForFreeMemory.RLock()
+	calls = mock.calls.WaitForFreeMemory
+	mock.lockWaitForFreeMemory.RUnlock()
+	return calls
+}
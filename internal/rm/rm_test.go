@@ -183,7 +183,7 @@ func TestValidateRequest(t *testing.T) {
 				config: &spec.Config{
 					Sharing: tc.sharing,
 				},
-				devices: tc.devices,
+				devices: newDeviceSnapshot(tc.devices),
 			}
 			err := r.ValidateRequest(tc.requestDevicesIDs)
 			require.ErrorIs(t, err, tc.expectedError)
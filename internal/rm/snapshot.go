@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// deviceSnapshot holds an immutable snapshot of a set of devices.
+//
+// The devices are read concurrently by the health checks, the allocation
+// functions and the MPS daemon. Readers load the current snapshot without
+// locking, and updates are made by atomically replacing the snapshot with a
+// modified copy. Neither the Devices map nor the Device values in a snapshot
+// may be modified once it has been stored.
+type deviceSnapshot struct {
+	devices atomic.Pointer[Devices]
+}
+
+// newDeviceSnapshot creates a snapshot of the specified devices.
+func newDeviceSnapshot(devices Devices) *deviceSnapshot {
+	s := &deviceSnapshot{}
+	s.devices.Store(&devices)
+	return s
+}
+
+// Load returns the current snapshot of the devices.
+func (s *deviceSnapshot) Load() Devices {
+	return *s.devices.Load()
+}
+
+// update replaces the current snapshot with the result of applying fn to a
+// copy of it. The fn function may add and remove entries of the map it is
+// passed, but must replace (and not modify) any Device that is updated. Since
+// fn is retried if the snapshot is replaced concurrently, it should not have
+// side effects.
+func (s *deviceSnapshot) update(fn func(Devices)) {
+	for {
+		current := s.devices.Load()
+		updated := maps.Clone(*current)
+		fn(updated)
+		if s.devices.CompareAndSwap(current, &updated) {
+			return
+		}
+	}
+}
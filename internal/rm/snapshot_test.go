@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+func TestSetDeviceHealthDoesNotModifySnapshot(t *testing.T) {
+	r := &resourceManager{
+		devices: newDeviceSnapshot(Devices{
+			"GPU-0": &Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+			"GPU-1": &Device{Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}},
+		}),
+	}
+
+	before := r.Devices()
+	r.SetDeviceHealth("GPU-1", pluginapi.Unhealthy, HealthReasonXID)
+	r.SetDeviceHealth("GPU-2", pluginapi.Unhealthy, HealthReasonXID)
+	after := r.Devices()
+
+	require.Equal(t, pluginapi.Healthy, before["GPU-1"].Health)
+	require.Equal(t, pluginapi.Unhealthy, after["GPU-1"].Health)
+	require.Same(t, before["GPU-0"], after["GPU-0"])
+	require.Len(t, after, 2)
+}
+
+func TestSetDeviceHealthTracksReasons(t *testing.T) {
+	type update struct {
+		health string
+		reason HealthReason
+	}
+	testCases := []struct {
+		description    string
+		updates        []update
+		expectedHealth string
+	}{
+		{
+			description: "single reason cleared",
+			updates: []update{
+				{pluginapi.Unhealthy, HealthReasonThrottle},
+				{pluginapi.Healthy, HealthReasonThrottle},
+			},
+			expectedHealth: pluginapi.Healthy,
+		},
+		{
+			description: "recovery does not clear other reasons",
+			updates: []update{
+				{pluginapi.Unhealthy, HealthReasonXID},
+				{pluginapi.Unhealthy, HealthReasonThrottle},
+				{pluginapi.Healthy, HealthReasonThrottle},
+			},
+			expectedHealth: pluginapi.Unhealthy,
+		},
+		{
+			description: "all reasons cleared",
+			updates: []update{
+				{pluginapi.Unhealthy, HealthReasonFabric},
+				{pluginapi.Unhealthy, HealthReasonMPS},
+				{pluginapi.Healthy, HealthReasonFabric},
+				{pluginapi.Healthy, HealthReasonMPS},
+			},
+			expectedHealth: pluginapi.Healthy,
+		},
+		{
+			description: "healthy from unrelated source",
+			updates: []update{
+				{pluginapi.Unhealthy, HealthReasonXID},
+				{pluginapi.Healthy, HealthReasonFabric},
+			},
+			expectedHealth: pluginapi.Unhealthy,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := &resourceManager{
+				devices: newDeviceSnapshot(Devices{
+					"GPU-0": &Device{Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}},
+				}),
+			}
+
+			var health string
+			for _, u := range tc.updates {
+				health = r.SetDeviceHealth("GPU-0", u.health, u.reason)
+			}
+
+			require.Equal(t, tc.expectedHealth, health)
+			require.Equal(t, tc.expectedHealth, r.Devices()["GPU-0"].Health)
+		})
+	}
+}
+
+// TestConcurrentDeviceAccess is intended to be run with -race.
+func TestConcurrentDeviceAccess(t *testing.T) {
+	devices := make(Devices)
+	for i := 0; i < 8; i++ {
+		id := string(NewAnnotatedID("GPU-0", i))
+		devices[id] = &Device{Device: pluginapi.Device{ID: id, Health: pluginapi.Healthy}}
+	}
+	r := &resourceManager{
+		config:  &spec.Config{},
+		devices: newDeviceSnapshot(devices),
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range devices.GetIDs() {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.SetDeviceHealth(id, pluginapi.Unhealthy, HealthReasonXID)
+		}()
+		go func() {
+			defer wg.Done()
+			for _, d := range r.Devices() {
+				_ = d.Health
+			}
+			_ = r.Devices().GetPluginDevices()
+			_, _ = r.distributedAlloc(r.Devices().GetIDs(), nil, 1)
+		}()
+	}
+	wg.Wait()
+
+	for _, d := range r.Devices() {
+		require.Equal(t, pluginapi.Unhealthy, d.Health)
+	}
+}
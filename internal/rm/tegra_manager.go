@@ -17,6 +17,7 @@
 package rm
 
 import (
+	"context"
 	"fmt"
 
 	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
@@ -49,7 +50,7 @@ func NewTegraResourceManagers(config *spec.Config) ([]ResourceManager, error) {
 			resourceManager: resourceManager{
 				config:   config,
 				resource: resourceName,
-				devices:  devices,
+				devices:  newDeviceSnapshot(devices),
 			},
 		}
 		if len(devices) != 0 {
@@ -70,7 +71,17 @@ func (r *tegraResourceManager) GetDevicePaths(ids []string) []string {
 	return nil
 }
 
+// GetNCCLEnvs returns no envvars for the tegraResourceManager
+func (r *tegraResourceManager) GetNCCLEnvs(ids []string) (map[string]string, error) {
+	return nil, nil
+}
+
+// WaitForFreeMemory does not wait for the tegraResourceManager
+func (r *tegraResourceManager) WaitForFreeMemory(ctx context.Context, ids []string) error {
+	return nil
+}
+
 // CheckHealth is disabled for the tegraResourceManager
-func (r *tegraResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
+func (r *tegraResourceManager) CheckHealth(stop <-chan interface{}, health chan<- *Device) error {
 	return nil
 }
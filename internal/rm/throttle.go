@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "github.com/NVIDIA/k8s-device-plugin/api/config/v1"
+)
+
+// throttleSustainedPeriod defines how long a GPU must be throttled
+// continuously before its devices are marked as unhealthy. This prevents
+// short throttling events under load from affecting device health.
+const throttleSustainedPeriod = 5 * time.Minute
+
+// throttleReasons maps the throttle reasons accepted in the config to the
+// corresponding NVML clock event reasons.
+var throttleReasons = map[string]uint64{
+	spec.ThrottleReasonThermal: nvml.ClocksEventReasonSwThermalSlowdown | nvml.ClocksThrottleReasonHwThermalSlowdown,
+	spec.ThrottleReasonPower:   nvml.ClocksThrottleReasonHwPowerBrakeSlowdown,
+}
+
+// throttleChecker tracks how long each GPU has been throttled for.
+type throttleChecker struct {
+	reasons  uint64
+	capacity float64
+	gpus     map[string]*throttledGPU
+}
+
+// throttledGPU stores the throttle state of a single GPU and the devices that
+// it backs.
+type throttledGPU struct {
+	gpu     nvml.Device
+	devices []*Device
+	// since is the time at which throttling was first observed, or the zero
+	// value if the GPU is not currently throttled.
+	since time.Time
+	// marked stores the devices that were marked as unhealthy because the GPU
+	// is throttled. These are marked as healthy once the throttling stops.
+	marked []*Device
+}
+
+// newThrottleChecker creates a throttle checker for the specified throttle
+// reasons. A throttled GPU keeps the specified fraction of its devices
+// healthy; for replicated GPUs this allows the advertised capacity of a
+// throttled GPU to be reduced instead of removing it entirely.
+func newThrottleChecker(names []string, capacity float64) *throttleChecker {
+	c := &throttleChecker{
+		capacity: capacity,
+		gpus:     make(map[string]*throttledGPU),
+	}
+	for _, name := range names {
+		reasons, ok := throttleReasons[name]
+		if !ok {
+			klog.Infof("Ignoring unknown throttle reason %v", name)
+			continue
+		}
+		c.reasons |= reasons
+	}
+	return c
+}
+
+// newThrottleChecker creates a throttle checker from the config of the
+// resource manager.
+func (r *nvmlResourceManager) newThrottleChecker() *throttleChecker {
+	var names []string
+	var capacity float64
+	if r.config != nil && r.config.Flags.Plugin != nil {
+		if r.config.Flags.Plugin.ThrottleHealthChecks != nil {
+			names = *r.config.Flags.Plugin.ThrottleHealthChecks
+		}
+		if r.config.Flags.Plugin.ThrottledCapacity != nil {
+			capacity = *r.config.Flags.Plugin.ThrottledCapacity
+		}
+	}
+	return newThrottleChecker(names, capacity)
+}
+
+// enabled checks whether any throttle reasons are treated as health events.
+func (c *throttleChecker) enabled() bool {
+	return c.reasons != 0
+}
+
+// add registers a device backed by the GPU with the specified UUID.
+func (c *throttleChecker) add(uuid string, gpu nvml.Device, d *Device) {
+	if c.gpus[uuid] == nil {
+		c.gpus[uuid] = &throttledGPU{gpu: gpu}
+	}
+	c.gpus[uuid].devices = append(c.gpus[uuid].devices, d)
+}
+
+// check queries the current clock event reasons of each GPU and marks devices
+// on GPUs that have been throttled for longer than throttleSustainedPeriod as
+// unhealthy. Once a GPU is no longer throttled, its devices are marked as
+// healthy again.
+func (c *throttleChecker) check(now time.Time, health chan<- *Device) {
+	if !c.enabled() {
+		return
+	}
+	for uuid, g := range c.gpus {
+		reasons, ret := g.gpu.GetCurrentClocksEventReasons()
+		if ret != nvml.SUCCESS {
+			klog.V(4).Infof("Unable to get clock event reasons for %v: %v", uuid, ret)
+			continue
+		}
+		if reasons&c.reasons == 0 {
+			for _, d := range g.marked {
+				klog.Infof("GPU %v is no longer throttled; marking device %v as healthy.", uuid, d.ID)
+				health <- d.WithHealth(pluginapi.Healthy, HealthReasonThrottle)
+			}
+			g.marked = nil
+			g.since = time.Time{}
+			continue
+		}
+		if g.since.IsZero() {
+			klog.Infof("GPU %v is throttled (reasons=0x%x)", uuid, reasons)
+			g.since = now
+			continue
+		}
+		if g.marked != nil || now.Sub(g.since) < throttleSustainedPeriod {
+			continue
+		}
+
+		g.marked = g.devicesToMark(c.capacity)
+		for _, d := range g.marked {
+			klog.Infof("GPU %v has been throttled since %v (reasons=0x%x); marking device %v as unhealthy.", uuid, g.since.Format(time.RFC3339), reasons, d.ID)
+			health <- d.WithHealth(pluginapi.Unhealthy, HealthReasonThrottle)
+		}
+	}
+}
+
+// devicesToMark returns the devices on a throttled GPU that should be marked
+// as unhealthy so that at most the specified fraction of its devices remain.
+// Replicas with the highest replica numbers are selected first so that the
+// selection is stable.
+func (g *throttledGPU) devicesToMark(capacity float64) []*Device {
+	devices := append([]*Device{}, g.devices...)
+	sort.Slice(devices, func(i, j int) bool {
+		iid, ireplica := AnnotatedID(devices[i].ID).Split()
+		jid, jreplica := AnnotatedID(devices[j].ID).Split()
+		if iid != jid {
+			return iid > jid
+		}
+		return ireplica > jreplica
+	})
+	remaining := int(capacity * float64(len(devices)))
+	return devices[:len(devices)-remaining]
+}
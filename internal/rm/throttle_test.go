@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// throttleTestDevice returns the clock event reasons in order, repeating the
+// last one.
+type throttleTestDevice struct {
+	nvml.Device
+	reasons []uint64
+}
+
+func (d *throttleTestDevice) GetCurrentClocksEventReasons() (uint64, nvml.Return) {
+	reasons := d.reasons[0]
+	if len(d.reasons) > 1 {
+		d.reasons = d.reasons[1:]
+	}
+	return reasons, nvml.SUCCESS
+}
+
+func TestThrottleChecker(t *testing.T) {
+	const thermal = nvml.ClocksThrottleReasonHwThermalSlowdown
+
+	testCases := []struct {
+		description       string
+		checks            []string
+		capacity          float64
+		reasons           []uint64
+		expectedUnhealthy []string
+		expectedHealthy   []string
+	}{
+		{
+			description: "throttle health checks disabled",
+			reasons:     []uint64{thermal},
+		},
+		{
+			description: "throttle reason not selected",
+			checks:      []string{"power"},
+			reasons:     []uint64{thermal},
+		},
+		{
+			description:       "sustained throttling",
+			checks:            []string{"thermal"},
+			reasons:           []uint64{thermal},
+			expectedUnhealthy: []string{"GPU-0::0", "GPU-0::1", "GPU-0::2", "GPU-0::3"},
+		},
+		{
+			description: "intermittent throttling",
+			checks:      []string{"thermal"},
+			reasons:     []uint64{thermal, thermal, 0, thermal},
+		},
+		{
+			description:       "reduced capacity",
+			checks:            []string{"thermal", "power"},
+			capacity:          0.5,
+			reasons:           []uint64{thermal},
+			expectedUnhealthy: []string{"GPU-0::2", "GPU-0::3"},
+		},
+		{
+			description:       "throttling stops",
+			checks:            []string{"thermal"},
+			capacity:          0.5,
+			reasons:           []uint64{thermal, thermal, thermal, 0},
+			expectedUnhealthy: []string{"GPU-0::2", "GPU-0::3"},
+			expectedHealthy:   []string{"GPU-0::2", "GPU-0::3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			c := newThrottleChecker(tc.checks, tc.capacity)
+			gpu := &throttleTestDevice{reasons: tc.reasons}
+			for i := 0; i < 4; i++ {
+				id := string(NewAnnotatedID("GPU-0", i))
+				c.add("GPU-0", gpu, &Device{Device: pluginapi.Device{ID: id}})
+			}
+
+			health := make(chan *Device, 8)
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				c.check(start.Add(time.Duration(i)*throttleSustainedPeriod/2), health)
+			}
+			close(health)
+
+			var unhealthy, healthy []string
+			for d := range health {
+				switch d.Health {
+				case pluginapi.Unhealthy:
+					unhealthy = append(unhealthy, d.ID)
+				case pluginapi.Healthy:
+					healthy = append(healthy, d.ID)
+				}
+			}
+			require.ElementsMatch(t, tc.expectedUnhealthy, unhealthy)
+			require.ElementsMatch(t, tc.expectedHealthy, healthy)
+		})
+	}
+}
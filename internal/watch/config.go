@@ -0,0 +1,86 @@
+/*
+# Copyright NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+*/
+
+package watch
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ConfigFile watches a config file for changes to its contents.
+//
+// Config files are typically ConfigMap keys that are symlinked into place,
+// and ConfigMaps are updated by swapping symlinks rather than by writing to
+// the file. The directories containing the file and its symlink target are
+// therefore watched, and an event is only a change if the contents of the
+// file differ.
+type ConfigFile struct {
+	*fsnotify.Watcher
+	path string
+	sum  [sha256.Size]byte
+}
+
+// NewConfigFile creates a watcher for the specified config file.
+func NewConfigFile(path string) (*ConfigFile, error) {
+	watcher, err := Files(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	f := &ConfigFile{
+		Watcher: watcher,
+		path:    path,
+	}
+	f.watchTarget()
+	// The initial contents are recorded so that only subsequent changes are
+	// reported. A missing file is reported as changed once it is created.
+	_, _ = f.Changed()
+	return f, nil
+}
+
+// Changed checks whether the contents of the config file have changed since
+// the last call.
+func (f *ConfigFile) Changed() (bool, error) {
+	// The symlink may have been updated to point to a different directory.
+	f.watchTarget()
+
+	contents, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(contents)
+	if sum == f.sum {
+		return false, nil
+	}
+	f.sum = sum
+	return true, nil
+}
+
+// watchTarget adds a watch for the directory containing the target of the
+// config file if it is a symlink.
+func (f *ConfigFile) watchTarget() {
+	target, err := os.Readlink(f.path)
+	if err != nil {
+		return
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(f.path), target)
+	}
+	_ = f.Add(filepath.Dir(target))
+}
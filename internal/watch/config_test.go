@@ -0,0 +1,82 @@
+/*
+# Copyright NVIDIA CORPORATION
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+*/
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFile(t *testing.T) {
+	configDir := t.TempDir()
+	availableDir := t.TempDir()
+
+	first := filepath.Join(availableDir, "first")
+	second := filepath.Join(availableDir, "second")
+	require.NoError(t, os.WriteFile(first, []byte("version: v1\n"), 0600))
+	require.NoError(t, os.WriteFile(second, []byte("version: v1\nflags: {}\n"), 0600))
+
+	path := filepath.Join(configDir, "config.yaml")
+	require.NoError(t, os.Symlink(first, path))
+
+	f, err := NewConfigFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	changed, err := f.Changed()
+	require.NoError(t, err)
+	require.False(t, changed, "initial contents are not a change")
+
+	// Updating the contents of the symlink target triggers an event in the
+	// target directory.
+	require.NoError(t, os.WriteFile(first, []byte("version: v1\nsharing: {}\n"), 0600))
+	waitForEvent(t, f)
+	changed, err = f.Changed()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	// Pointing the symlink at a file with the same contents is not a change.
+	require.NoError(t, os.WriteFile(second, []byte("version: v1\nsharing: {}\n"), 0600))
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Symlink(second, path))
+	waitForEvent(t, f)
+	changed, err = f.Changed()
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func waitForEvent(t *testing.T, f *ConfigFile) {
+	select {
+	case <-f.Events:
+	case err := <-f.Errors:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for event")
+	}
+	// Drain any further events caused by the same update.
+	for {
+		select {
+		case <-f.Events:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}